@@ -0,0 +1,208 @@
+/*
+  Copyright 2011 Alec Thomas
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+*/
+
+// Editing tasks as indented text in the user's editor.
+//
+// Each task is written on its own line, indented four spaces per level:
+//
+//   @12 [ ] (high) Fix the parser +backend
+//       @13 [x] Write a test
+//
+// The ID identifies existing tasks, so lines may be freely reordered and
+// re-indented. Lines without an ID are new tasks, and tasks whose lines are
+// deleted are removed. Tasks that are otherwise unchanged keep their creation
+// and completion times, notes, due times and attributes.
+
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const editableHeader = `# Edit tasks below, one per line, indenting sub-tasks by four spaces:
+#
+#   [@<id>] [x] [(<priority>)] <text>
+#
+# @<id> identifies an existing task and should be left as is. Lines without
+# an ID create new tasks, and deleting a line removes its task. [x] marks a
+# task done. Priorities are veryhigh, high, low or verylow, and default to
+# medium. Lines starting with # are ignored.
+
+`
+
+var editableLinePattern = regexp.MustCompile(`^(?:@(\d+)\s+)?(?:\[([ xX]?)\]\s*)?(?:\((veryhigh|high|medium|low|verylow)\)\s*)?(.*)$`)
+
+// Text that would be read back as a priority if it were not preceded by one.
+var editablePriorityPattern = regexp.MustCompile(`^\((veryhigh|high|medium|low|verylow)\)`)
+
+// A task as parsed from edited text.
+type editedTask struct {
+	ID       int
+	Done     bool
+	Priority Priority
+	Text     string
+	Tags     []string
+	Children []*editedTask
+}
+
+func formatEditableTasks(tasks []Task) string {
+	out := &strings.Builder{}
+	out.WriteString(editableHeader)
+	var format func(depth int, task Task)
+	format = func(depth int, task Task) {
+		state := " "
+		if !task.CompletionTime().IsZero() {
+			state = "x"
+		}
+		// Medium is the default, so is only written to keep text such as
+		// "(low) battery" from being read as a priority.
+		priority := ""
+		if task.Priority() != MEDIUM || editablePriorityPattern.MatchString(task.Text()) {
+			priority = "(" + task.Priority().String() + ") "
+		}
+		text := strings.Join(append([]string{task.Text()}, task.Tags()...), " ")
+		fmt.Fprintf(out, "%s%s%d [%s] %s%s\n", strings.Repeat("    ", depth), IDPrefix, task.ID(), state, priority, text)
+		for i := 0; i < task.Len(); i++ {
+			format(depth+1, task.At(i))
+		}
+	}
+	for _, task := range tasks {
+		format(0, task)
+	}
+	return out.String()
+}
+
+func parseEditableTasks(text string) []*editedTask {
+	type level struct {
+		indent int
+		task   *editedTask
+	}
+	root := &editedTask{}
+	stack := []level{{-1, root}}
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimRight(line, " \t\r")
+		content := strings.TrimLeft(line, " \t")
+		if content == "" || strings.HasPrefix(content, "#") {
+			continue
+		}
+		indent := 0
+		for _, c := range line[:len(line)-len(content)] {
+			if c == '\t' {
+				indent += 4
+			} else {
+				indent++
+			}
+		}
+		match := editableLinePattern.FindStringSubmatch(content)
+		task := &editedTask{Priority: MEDIUM}
+		task.ID, _ = strconv.Atoi(match[1])
+		task.Done = strings.EqualFold(match[2], "x")
+		if match[3] != "" {
+			task.Priority = PriorityFromString(match[3])
+		}
+		task.Text, task.Tags = ParseTags(match[4])
+		// The parent is the closest preceding task that is less indented.
+		for stack[len(stack)-1].indent >= indent {
+			stack = stack[:len(stack)-1]
+		}
+		parent := stack[len(stack)-1].task
+		parent.Children = append(parent.Children, task)
+		stack = append(stack, level{indent, task})
+	}
+	return root.Children
+}
+
+// Replace original, a list of consecutive siblings starting at position in
+// parent, with the edited tasks.
+func applyEditedTasks(parent TaskNode, position int, original []Task, edited []*editedTask) {
+	known := map[int]Task{}
+	var collect func(task Task)
+	collect = func(task Task) {
+		known[task.ID()] = task
+		for i := 0; i < task.Len(); i++ {
+			collect(task.At(i))
+		}
+	}
+	for _, task := range original {
+		collect(task)
+	}
+	// Detach everything, then reassemble the tree from the edited text.
+	for _, task := range original {
+		task.Delete()
+	}
+	for _, task := range known {
+		for task.Len() > 0 {
+			task.At(0).Delete()
+		}
+	}
+	var build func(parent TaskNode, position int, entries []*editedTask)
+	build = func(parent TaskNode, position int, entries []*editedTask) {
+		for i, entry := range entries {
+			task, ok := known[entry.ID]
+			if ok {
+				delete(known, entry.ID)
+				MoveTask(task, parent, position+i)
+			} else {
+				task = parent.Create(entry.Text, entry.Priority)
+				MoveTask(task, parent, position+i)
+			}
+			task.SetText(entry.Text)
+			task.SetTags(entry.Tags)
+			task.SetPriority(entry.Priority)
+			if entry.Done && task.CompletionTime().IsZero() {
+				task.SetCompleted()
+			} else if !entry.Done && !task.CompletionTime().IsZero() {
+				task.SetCompletionTime(time.Time{})
+			}
+			build(task, 0, entry.Children)
+		}
+	}
+	build(parent, position, edited)
+}
+
+// Edit a task and its descendants, or the whole task list if task is nil.
+func doEditInEditor(tasks TaskList, task Task) {
+	var parent TaskNode = tasks // -golint
+	position := 0
+	original := []Task{}
+	if task != nil {
+		parent = task.Parent()
+		position = Position(task)
+		original = append(original, task)
+	} else {
+		for i := 0; i < tasks.Len(); i++ {
+			original = append(original, tasks.At(i))
+		}
+	}
+	before := formatEditableTasks(original)
+	after, err := editText(before, "todo2-*.txt")
+	if err != nil {
+		fatalf("%s", err)
+	}
+	if after == before {
+		return
+	}
+	edited := parseEditableTasks(after)
+	if len(edited) == 0 && len(original) > 0 {
+		fatalf("no tasks left in edited file, aborting (use --remove to remove tasks)")
+	}
+	applyEditedTasks(parent, position, original, edited)
+	saveTaskList(tasks)
+}
@@ -0,0 +1,72 @@
+/*
+  Copyright 2011 Alec Thomas
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+*/
+
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestEditableTextLikePriority(t *testing.T) {
+	tasks := NewTaskList()
+	medium := tasks.Create("(low) battery warning", MEDIUM)
+	high := tasks.Create("(verylow) first", HIGH)
+	parsed := parseEditableTasks(formatEditableTasks([]Task{medium, high}))
+	if len(parsed) != 2 || parsed[0].Priority != MEDIUM || parsed[0].Text != "(low) battery warning" {
+		t.Errorf("unexpected medium task %+v", parsed[0])
+	}
+	if parsed[1].Priority != HIGH || parsed[1].Text != "(verylow) first" {
+		t.Errorf("unexpected high task %+v", parsed[1])
+	}
+}
+
+func TestApplyEditedTasks(t *testing.T) {
+	tasks := NewTaskList()
+	a := tasks.Create("do A", MEDIUM)
+	b := a.Create("do B", LOW)
+	c := tasks.Create("do C", HIGH)
+	created := time.Date(2011, 1, 1, 0, 0, 0, 0, time.UTC)
+	c.SetCreationTime(created)
+	c.SetNote("keep me")
+
+	text := formatEditableTasks([]Task{a, c})
+	if parsed := parseEditableTasks(text); len(parsed) != 2 || len(parsed[0].Children) != 1 || parsed[1].Priority != HIGH {
+		t.Fatalf("round trip failed for %q", text)
+	}
+
+	edited := parseEditableTasks(`# comment
+@3 [ ] (high) do C
+	@2 [x] do B +later
+[ ] (verylow) do D
+`)
+	applyEditedTasks(tasks, 0, []Task{a, c}, edited)
+	if tasks.Len() != 2 || tasks.At(0) != c || tasks.At(0).At(0) != b || tasks.At(1).Text() != "do D" {
+		t.Fatal("tree was not rebuilt as edited")
+	}
+	if tasks.FindByID(1) != nil {
+		t.Error("deleted task should be removed")
+	}
+	if !c.CreationTime().Equal(created) || c.Note() != "keep me" {
+		t.Error("unchanged task should keep its details")
+	}
+	if b.CompletionTime().IsZero() || b.Text() != "do B" || len(b.Tags()) != 1 {
+		t.Error("edits to existing task not applied")
+	}
+	if d := tasks.At(1); d.ID() != 4 || d.Priority() != VERYLOW {
+		t.Error("new task not created")
+	}
+}
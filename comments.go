@@ -0,0 +1,307 @@
+/*
+  Copyright 2011 Alec Thomas
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+*/
+
+// A simple, language-aware scanner for comments in source code.
+//
+// The scanner understands just enough of each language (comment and string
+// delimiters) to avoid mistaking string literals for comments.
+
+package main
+
+import (
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+type stringSyntax struct {
+	Delimiter string
+	Escapes   bool // Backslash escapes the next character.
+	MultiLine bool // Otherwise strings are terminated by the end of a line.
+}
+
+type commentSyntax struct {
+	Line  []string    // Line comment prefixes.
+	Block [][2]string // Block comment delimiters.
+	// Strings, longest delimiter first.
+	Strings []stringSyntax
+	// Line comments must be preceded by whitespace (eg. "#" in shell, where
+	// "$#" is not a comment).
+	LineNeedsSpace bool
+	// Block comments must start at the beginning of a line (eg. Ruby's
+	// =begin/=end).
+	BlockAtLineStart bool
+	// Single quotes start character literals such as '"', but are otherwise
+	// not strings (eg. Rust lifetimes).
+	CharLiterals bool
+}
+
+var charLiteralPattern = regexp.MustCompile(`^'(?:[^'\\\n]|\\(?:x[0-9a-fA-F]{2}|u\{[0-9a-fA-F]{1,6}\}|.))'`)
+
+var (
+	cStrings = []stringSyntax{{`"`, true, false}, {`'`, true, false}}
+
+	cSyntax = &commentSyntax{
+		Line:    []string{"//"},
+		Block:   [][2]string{{"/*", "*/"}},
+		Strings: cStrings,
+	}
+	goSyntax = &commentSyntax{
+		Line:    []string{"//"},
+		Block:   [][2]string{{"/*", "*/"}},
+		Strings: append([]stringSyntax{{"`", false, true}}, cStrings...),
+	}
+	jsSyntax = &commentSyntax{
+		Line:    []string{"//"},
+		Block:   [][2]string{{"/*", "*/"}},
+		Strings: append([]stringSyntax{{"`", true, true}}, cStrings...),
+	}
+	// Single quotes are lifetimes and characters in Rust, so are not treated
+	// as strings.
+	rustSyntax = &commentSyntax{
+		Line:         []string{"//"},
+		Block:        [][2]string{{"/*", "*/"}},
+		Strings:      []stringSyntax{{`"`, true, true}},
+		CharLiterals: true,
+	}
+	pythonSyntax = &commentSyntax{
+		Line: []string{"#"},
+		Strings: append([]stringSyntax{
+			{`"""`, true, true},
+			{`'''`, true, true},
+		}, cStrings...),
+	}
+	shellSyntax = &commentSyntax{
+		Line:           []string{"#"},
+		Strings:        []stringSyntax{{`"`, true, true}, {`'`, false, true}},
+		LineNeedsSpace: true,
+	}
+	rubySyntax = &commentSyntax{
+		Line:             []string{"#"},
+		Block:            [][2]string{{"=begin", "=end"}},
+		Strings:          cStrings,
+		BlockAtLineStart: true,
+	}
+	sqlSyntax = &commentSyntax{
+		Line:    []string{"--"},
+		Block:   [][2]string{{"/*", "*/"}},
+		Strings: []stringSyntax{{`'`, false, true}, {`"`, false, true}},
+	}
+)
+
+var commentSyntaxByExtension = map[string]*commentSyntax{
+	".go":   goSyntax,
+	".c":    cSyntax,
+	".h":    cSyntax,
+	".cc":   cSyntax,
+	".cpp":  cSyntax,
+	".cxx":  cSyntax,
+	".hh":   cSyntax,
+	".hpp":  cSyntax,
+	".hxx":  cSyntax,
+	".java": cSyntax,
+	".js":   jsSyntax,
+	".jsx":  jsSyntax,
+	".mjs":  jsSyntax,
+	".cjs":  jsSyntax,
+	".ts":   jsSyntax,
+	".tsx":  jsSyntax,
+	".rs":   rustSyntax,
+	".py":   pythonSyntax,
+	".sh":   shellSyntax,
+	".bash": shellSyntax,
+	".zsh":  shellSyntax,
+	".rb":   rubySyntax,
+	".sql":  sqlSyntax,
+}
+
+// Return the comment syntax for a file, or nil if its language is unknown.
+func commentSyntaxForFile(file string) *commentSyntax {
+	return commentSyntaxByExtension[strings.ToLower(filepath.Ext(file))]
+}
+
+// A single line of a comment.
+type commentLine struct {
+	Line   int    // 1-based line number.
+	Column int    // 1-based byte offset of Text in its line.
+	Text   string // Comment text, excluding delimiters.
+	// Identifies the comment this line belongs to. Each line comment is
+	// distinct, while all lines of a block comment share an ID.
+	ID int
+	// Only whitespace precedes the comment on its line.
+	Leading bool
+	// Column of the line comment delimiter, or 0 for block comments.
+	Delimiter int
+}
+
+type commentScanner struct {
+	syntax    *commentSyntax
+	data      string
+	pos       int
+	line      int
+	lineStart int
+	id        int
+	comments  []commentLine
+}
+
+// Scan data for comments.
+func scanComments(data string, syntax *commentSyntax) []commentLine {
+	s := &commentScanner{syntax: syntax, data: data, line: 1}
+	for s.pos < len(s.data) {
+		if s.data[s.pos] == '\n' {
+			s.advance(1)
+		} else if n := s.charLiteralAt(); n > 0 {
+			s.advance(n)
+		} else if str := s.stringAt(); str != nil {
+			s.skipString(str)
+		} else if prefix := s.lineCommentAt(); prefix != "" {
+			s.scanLineComment(prefix)
+		} else if block := s.blockCommentAt(); block != nil {
+			s.scanBlockComment(block)
+		} else {
+			s.advance(1)
+		}
+	}
+	return s.comments
+}
+
+// Advance n bytes, tracking line numbers.
+func (s *commentScanner) advance(n int) {
+	for end := s.pos + n; s.pos < end && s.pos < len(s.data); s.pos++ {
+		if s.data[s.pos] == '\n' {
+			s.line++
+			s.lineStart = s.pos + 1
+		}
+	}
+}
+
+func (s *commentScanner) leading() bool {
+	return strings.TrimSpace(s.data[s.lineStart:s.pos]) == ""
+}
+
+// Length of the character literal at the current position, or 0.
+func (s *commentScanner) charLiteralAt() int {
+	if !s.syntax.CharLiterals || s.data[s.pos] != '\'' {
+		return 0
+	}
+	return len(charLiteralPattern.FindString(s.data[s.pos:]))
+}
+
+func (s *commentScanner) stringAt() *stringSyntax {
+	for i, str := range s.syntax.Strings {
+		if strings.HasPrefix(s.data[s.pos:], str.Delimiter) {
+			return &s.syntax.Strings[i]
+		}
+	}
+	return nil
+}
+
+func (s *commentScanner) skipString(str *stringSyntax) {
+	s.advance(len(str.Delimiter))
+	for s.pos < len(s.data) {
+		switch {
+		case str.Escapes && s.data[s.pos] == '\\':
+			s.advance(2)
+		case strings.HasPrefix(s.data[s.pos:], str.Delimiter):
+			s.advance(len(str.Delimiter))
+			return
+		case s.data[s.pos] == '\n' && !str.MultiLine:
+			return
+		default:
+			s.advance(1)
+		}
+	}
+}
+
+func (s *commentScanner) lineCommentAt() string {
+	for _, prefix := range s.syntax.Line {
+		if !strings.HasPrefix(s.data[s.pos:], prefix) {
+			continue
+		}
+		if s.syntax.LineNeedsSpace && s.pos > s.lineStart && !strings.ContainsRune(" \t", rune(s.data[s.pos-1])) {
+			continue
+		}
+		return prefix
+	}
+	return ""
+}
+
+func (s *commentScanner) scanLineComment(prefix string) {
+	s.id++
+	start := s.pos + len(prefix)
+	end := strings.IndexByte(s.data[start:], '\n')
+	if end == -1 {
+		end = len(s.data)
+	} else {
+		end += start
+	}
+	s.comments = append(s.comments, commentLine{
+		Line:      s.line,
+		Column:    start - s.lineStart + 1,
+		Text:      strings.TrimRight(s.data[start:end], "\r"),
+		ID:        s.id,
+		Leading:   s.leading(),
+		Delimiter: s.pos - s.lineStart + 1,
+	})
+	s.advance(end - s.pos)
+}
+
+func (s *commentScanner) blockCommentAt() *[2]string {
+	if s.syntax.BlockAtLineStart && s.pos != s.lineStart {
+		return nil
+	}
+	for i, block := range s.syntax.Block {
+		if strings.HasPrefix(s.data[s.pos:], block[0]) {
+			return &s.syntax.Block[i]
+		}
+	}
+	return nil
+}
+
+func (s *commentScanner) scanBlockComment(block *[2]string) {
+	s.id++
+	leading := s.leading()
+	s.advance(len(block[0]))
+	end := strings.Index(s.data[s.pos:], block[1])
+	if end == -1 {
+		end = len(s.data)
+	} else {
+		end += s.pos
+	}
+	// Emit each line of the comment separately.
+	for {
+		lineEnd := strings.IndexByte(s.data[s.pos:end], '\n')
+		if lineEnd == -1 {
+			lineEnd = end
+		} else {
+			lineEnd += s.pos
+		}
+		s.comments = append(s.comments, commentLine{
+			Line:    s.line,
+			Column:  s.pos - s.lineStart + 1,
+			Text:    strings.TrimRight(s.data[s.pos:lineEnd], "\r"),
+			ID:      s.id,
+			Leading: leading,
+		})
+		s.advance(lineEnd - s.pos)
+		if s.pos >= end {
+			break
+		}
+		s.advance(1)
+		leading = true
+	}
+	s.advance(len(block[1]))
+}
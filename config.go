@@ -0,0 +1,272 @@
+/*
+  Copyright 2011 Alec Thomas
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+*/
+
+// Configuration files.
+//
+// Settings are read, in increasing order of precedence, from ~/.todorc,
+// $XDG_CONFIG_HOME/todo2/config and .todo2rc in the current directory, and
+// are in turn overridden by command-line flags. Each line is a
+// "key = value" pair, and lines starting with # are ignored.
+
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/alecthomas/kingpin/v2"
+)
+
+// Settings that provide the default value of the command-line flag of the
+// same name.
+var configFlags = []string{"file", "legacy-file", "order", "lock-timeout", "group", "color"}
+
+type Config struct {
+	// Priority of new tasks when --priority is not given.
+	Priority string
+	// Filter used when displaying tasks without --filter or --all.
+	Filter string
+	// Templates used to display tasks, and to display them with --summary.
+	Format        string
+	SummaryFormat string
+	// Layout used to display dates, as a Go time layout.
+	DateFormat string
+	// Name of the colour theme.
+	Theme string
+	// Colours of display elements overriding the theme, eg.
+	// "title" => "bright green".
+	Colours map[string]string
+	// Command-line arguments that an alias expands to.
+	Aliases map[string][]string
+	// Default values of command-line flags.
+	Flags map[string]string
+}
+
+func newConfig() *Config {
+	return &Config{
+		Priority:   "medium",
+		Filter:     "not done",
+		DateFormat: "2006-01-02",
+		Theme:      "dark",
+		Colours:    map[string]string{},
+		Aliases:    map[string][]string{},
+		Flags:      map[string]string{},
+	}
+}
+
+// Paths of configuration files, in increasing order of precedence.
+func configPaths() []string {
+	paths := []string{}
+	if home, err := os.UserHomeDir(); err == nil {
+		paths = append(paths, filepath.Join(home, ".todorc"))
+	}
+	if dir, err := os.UserConfigDir(); err == nil {
+		paths = append(paths, filepath.Join(dir, "todo2", "config"))
+	}
+	return append(paths, ".todo2rc")
+}
+
+// Load all configuration files that exist.
+func loadConfig() (*Config, error) {
+	config := newConfig()
+	for _, path := range configPaths() {
+		// ~/.todorc may be a devtodo1 configuration, so ignore lines that are
+		// not settings.
+		err := config.Load(path, filepath.Base(path) != ".todorc")
+		if err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+	}
+	return config, nil
+}
+
+// Load settings from path. If strict is false, lines that are not valid
+// settings are ignored.
+func (c *Config) Load(path string, strict bool) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		parts := strings.SplitN(text, "=", 2)
+		if len(parts) != 2 {
+			if !strict {
+				continue
+			}
+			return fmt.Errorf("%s:%d: expected <key> = <value>", path, line)
+		}
+		if err := c.Set(strings.TrimSpace(parts[0]), strings.TrimSpace(parts[1])); err != nil {
+			if !strict {
+				continue
+			}
+			return fmt.Errorf("%s:%d: %s", path, line, err)
+		}
+	}
+	return scanner.Err()
+}
+
+func (c *Config) Set(key, value string) error {
+	switch {
+	case key == "priority":
+		if _, ok := priorityMapFromString[value]; !ok {
+			return fmt.Errorf("invalid priority '%s'", value)
+		}
+		c.Priority = value
+	case key == "filter":
+		c.Filter = value
+	case key == "format" || key == "summary-format":
+		if _, err := parseTaskFormat(value); err != nil {
+			return err
+		}
+		if key == "format" {
+			c.Format = value
+		} else {
+			c.SummaryFormat = value
+		}
+	case key == "date-format":
+		layout, err := strftimeToLayout(value)
+		if err != nil {
+			return err
+		}
+		c.DateFormat = layout
+	case key == "theme":
+		if _, ok := themes[value]; !ok {
+			return fmt.Errorf("unknown theme '%s'", value)
+		}
+		c.Theme = value
+	case strings.HasPrefix(key, "colour.") || strings.HasPrefix(key, "color."):
+		element := key[strings.Index(key, ".")+1:]
+		if !isColourElement(element) {
+			return fmt.Errorf("unknown colour element '%s'", element)
+		}
+		if _, err := parseColour(value); err != nil {
+			return err
+		}
+		c.Colours[element] = value
+	case strings.HasPrefix(key, "alias."):
+		args, err := splitArguments(value)
+		if err != nil {
+			return err
+		}
+		c.Aliases[key[len("alias."):]] = args
+	case containsString(configFlags, key):
+		c.Flags[key] = value
+	default:
+		return fmt.Errorf("unknown setting '%s'", key)
+	}
+	return nil
+}
+
+// Apply settings to the command-line parser and display. Must be called
+// before the command-line is parsed.
+func (c *Config) Apply(app *kingpin.Application) error {
+	for name, value := range c.Flags {
+		app.GetFlag(name).Default(value)
+	}
+	dateLayout = c.DateFormat
+	return applyColours(c.Theme, c.Colours)
+}
+
+// Replace an alias in the first argument with its expansion.
+func (c *Config) ExpandAlias(args []string) []string {
+	if len(args) == 0 {
+		return args
+	}
+	expansion, ok := c.Aliases[args[0]]
+	if !ok {
+		return args
+	}
+	return append(append([]string{}, expansion...), args[1:]...)
+}
+
+// Split a string into arguments, as the shell would, honouring quotes and
+// backslash escapes.
+func splitArguments(text string) ([]string, error) {
+	args := []string{}
+	current := &strings.Builder{}
+	inArg := false
+	var quote rune
+	escaped := false
+	for _, r := range text {
+		switch {
+		case escaped:
+			current.WriteRune(r)
+			escaped = false
+		case r == '\\' && quote != '\'':
+			escaped = true
+			inArg = true
+		case quote != 0 && r == quote:
+			quote = 0
+		case quote != 0:
+			current.WriteRune(r)
+		case r == '"' || r == '\'':
+			quote = r
+			inArg = true
+		case r == ' ' || r == '\t':
+			if inArg {
+				args = append(args, current.String())
+				current.Reset()
+				inArg = false
+			}
+		default:
+			current.WriteRune(r)
+			inArg = true
+		}
+	}
+	if quote != 0 || escaped {
+		return nil, fmt.Errorf("unterminated quote or escape in '%s'", text)
+	}
+	if inArg {
+		args = append(args, current.String())
+	}
+	return args, nil
+}
+
+var strftimeLayouts = map[byte]string{
+	'Y': "2006", 'y': "06", 'm': "01", 'd': "02", 'e': "_2", 'b': "Jan",
+	'B': "January", 'a': "Mon", 'A': "Monday", 'H': "15", 'I': "03",
+	'M': "04", 'S': "05", 'p': "PM", 'Z': "MST", 'z': "-0700", '%': "%",
+}
+
+// Convert a strftime format, eg. "%d/%m/%Y", into a Go time layout.
+func strftimeToLayout(format string) (string, error) {
+	layout := &strings.Builder{}
+	for i := 0; i < len(format); i++ {
+		if format[i] != '%' {
+			layout.WriteByte(format[i])
+			continue
+		}
+		if i+1 == len(format) {
+			return "", fmt.Errorf("incomplete date format '%s'", format)
+		}
+		i++
+		replacement, ok := strftimeLayouts[format[i]]
+		if !ok {
+			return "", fmt.Errorf("unsupported date format directive '%%%c'", format[i])
+		}
+		layout.WriteString(replacement)
+	}
+	return layout.String(), nil
+}
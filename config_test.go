@@ -0,0 +1,106 @@
+/*
+  Copyright 2011 Alec Thomas
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+*/
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestConfigLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config")
+	data := `# A comment.
+order = -due
+priority = low
+date-format = %d/%m/%Y
+colour.title = bright blue
+alias.urgent = -f "priority>=high and not done"
+`
+	if err := os.WriteFile(path, []byte(data), 0600); err != nil {
+		t.Fatal(err)
+	}
+	config := newConfig()
+	if err := config.Load(path, true); err != nil {
+		t.Fatal(err)
+	}
+	if config.Flags["order"] != "-due" || config.Priority != "low" || config.DateFormat != "02/01/2006" {
+		t.Errorf("unexpected config %+v", config)
+	}
+	if config.Colours["title"] != "bright blue" || config.Filter != "not done" {
+		t.Errorf("unexpected config %+v", config)
+	}
+	args := config.ExpandAlias([]string{"urgent", "-A"})
+	if !reflect.DeepEqual(args, []string{"-f", "priority>=high and not done", "-A"}) {
+		t.Errorf("unexpected alias expansion %q", args)
+	}
+
+	if err := os.WriteFile(path, []byte("sort -priority\nfile = .tasks\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := config.Load(path, true); err == nil {
+		t.Error("expected error for invalid line")
+	}
+	if err := config.Load(path, false); err != nil || config.Flags["file"] != ".tasks" {
+		t.Errorf("expected invalid lines to be ignored, got %v", err)
+	}
+}
+
+func TestConfigLoadDevtodoRC(t *testing.T) {
+	path := filepath.Join(t.TempDir(), ".todorc")
+	data := `# devtodo1 configuration
+verbose
+format display=%>(1)%1n.%f%2i%+1T
+format verbose-display=%>(1)%1n.%f%2i%+1T [%c]
+filter -children
+sort -done,-priority
+colour title=bold,+white
+priority = urgent
+order = -priority
+on add { echo added }
+`
+	if err := os.WriteFile(path, []byte(data), 0600); err != nil {
+		t.Fatal(err)
+	}
+	config := newConfig()
+	if err := config.Load(path, true); err == nil {
+		t.Error("expected error loading devtodo1 configuration strictly")
+	}
+	config = newConfig()
+	if err := config.Load(path, false); err != nil {
+		t.Fatal(err)
+	}
+	if config.Flags["order"] != "-priority" || config.Priority != newConfig().Priority {
+		t.Errorf("unexpected config %+v", config)
+	}
+}
+
+func TestConfigErrors(t *testing.T) {
+	config := newConfig()
+	for key, value := range map[string]string{
+		"priority":      "urgent",
+		"colour.title":  "sparkly",
+		"date-format":   "%Q",
+		"alias.broken":  `"unterminated`,
+		"unknown-thing": "1",
+	} {
+		if err := config.Set(key, value); err == nil {
+			t.Errorf("expected error setting %s = %s", key, value)
+		}
+	}
+}
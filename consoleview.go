@@ -18,9 +18,14 @@ package main
 
 import (
 	"fmt"
+	"math"
 	"os"
+	"sort"
+	"strconv"
 	"strings"
 	"syscall"
+	"text/template"
+	"time"
 	"unsafe"
 )
 
@@ -50,10 +55,27 @@ const (
 	BGCYAN     = "\x1b[46m"
 	BGWHITE    = "\x1b[47m"
 
+	// Shown after the index of tasks with notes.
+	NOTE_GLYPH = '*'
+)
+
+// Colours of display elements, which may be overridden by configuration.
+var (
 	TITLE_COLOUR = BRIGHT + FGGREEN
 	NUMBER_COLOR = FGGREEN
+	TAG_COLOUR   = FGMAGENTA
+	NOTE_COLOUR  = BRIGHT + FGCYAN
+	// Text of completed tasks, or the priority colour if empty.
+	DONE_COLOUR = ""
 )
 
+// Map for due state to ANSI colour
+var colourDueMap = map[DueState]string{
+	NOTDUE:  DIM,
+	DUESOON: BRIGHT + FGYELLOW,
+	OVERDUE: BRIGHT + FGRED + REVERSE,
+}
+
 // Map for priority level to ANSI colour
 var colourPriorityMap = map[Priority]string{
 	VERYHIGH: BRIGHT + FGRED,
@@ -63,30 +85,46 @@ var colourPriorityMap = map[Priority]string{
 	VERYLOW:  FGBLUE,
 }
 
+// Width to wrap output to, falling back to $COLUMNS if the width of the
+// terminal is unknown. Output that is redirected is not wrapped.
 func getTerminalWidth() int {
-	type winsize struct {
-		wsRow, wsCol       uint16
-		wsXPixel, wsYPixel uint16
+	terminal := isTerminal(os.Stdout.Fd())
+	if width, _ := terminalSize(os.Stdout.Fd()); terminal && width > 0 {
+		return width
+	}
+	if width, err := strconv.Atoi(os.Getenv("COLUMNS")); err == nil && width > 0 {
+		return width
+	}
+	if terminal {
+		return defaultTerminalWidth
 	}
+	return math.MaxInt32
+}
 
-	ws := winsize{}
-	syscall.Syscall(syscall.SYS_IOCTL,
-		uintptr(0), syscall.TIOCGWINSZ,
-		uintptr(unsafe.Pointer(&ws)))
-	return int(ws.wsCol)
+func isTerminal(fd uintptr) bool {
+	termios := syscall.Termios{}
+	_, _, err := syscall.Syscall(syscall.SYS_IOCTL, fd, ioctlGetTermios,
+		uintptr(unsafe.Pointer(&termios)))
+	return err == 0
 }
 
 func taskState(task Task) int {
-	if task.Len() != 0 {
-		return '+'
-	}
 	if !task.CompletionTime().IsZero() {
 		return '-'
 	}
+	if task.Len() != 0 {
+		return '+'
+	}
 	return ' '
 }
 
 func fatalf(format string, args ...interface{}) {
+	if restoreTerminal != nil {
+		restoreTerminal()
+	}
+	if unlockTaskList != nil {
+		unlockTaskList()
+	}
 	fmt.Fprintf(os.Stderr, "error: %s\n", fmt.Sprintf(format, args...))
 	os.Exit(1)
 }
@@ -96,13 +134,13 @@ func printWrappedText(text string, width, subsequentIndent int) {
 	offset := 0
 	for i, token := range tokens {
 		if i > 0 && offset+len(token) > width {
-			fmt.Printf("\n%s", strings.Repeat(" ", subsequentIndent))
+			fmt.Fprintf(stdout, "\n%s", strings.Repeat(" ", subsequentIndent))
 			offset = 0
 		}
-		fmt.Printf("%s", token)
+		fmt.Fprintf(stdout, "%s", token)
 		offset += len(token)
 		if offset < width && i != len(tokens)-1 {
-			fmt.Print(" ")
+			fmt.Fprint(stdout, " ")
 			offset++
 		}
 	}
@@ -112,35 +150,60 @@ func formatTask(width, depth int, task Task, options *ViewOptions) {
 	indent := depth*4 + 4
 	width -= indent
 	state := taskState(task)
-	fmt.Printf("%s%s%c%2d.%s%s", strings.Repeat("    ", depth), NUMBER_COLOR, state,
-		task.ID()+1, RESET, colourPriorityMap[task.Priority()])
+	fmt.Fprintf(stdout, "%s%s%c%2d.%s", strings.Repeat("    ", depth), NUMBER_COLOR, state,
+		Position(task)+1, RESET)
+	if task.Note() != "" {
+		fmt.Fprintf(stdout, "%s%c%s", NOTE_COLOUR, NOTE_GLYPH, RESET)
+	}
+	if !task.CompletionTime().IsZero() && DONE_COLOUR != "" {
+		fmt.Fprint(stdout, RESET+DONE_COLOUR)
+	} else {
+		fmt.Fprint(stdout, colourPriorityMap[task.Priority()])
+	}
 	text := task.Text()
 	trimmed := false
-	if options.Summarise {
+	if options.Summarise && width > 1 {
 		if len(text) > width {
 			text = strings.TrimSpace(text[:width-1])
 			trimmed = true
 		}
 	}
 	printWrappedText(text, width, indent)
+	formatTags(task)
+	formatDueTime(task)
 	if trimmed {
-		fmt.Printf("%s+%s\n", TITLE_COLOUR, RESET)
+		fmt.Fprintf(stdout, "%s+%s\n", TITLE_COLOUR, RESET)
 	} else {
-		fmt.Printf("%s\n", RESET)
+		fmt.Fprintf(stdout, "%s\n", RESET)
+	}
+}
+
+func formatTags(task Task) {
+	if len(task.Tags()) == 0 {
+		return
 	}
+	fmt.Fprintf(stdout, " %s%s%s", TAG_COLOUR, strings.Join(task.Tags(), " "), RESET)
 }
 
-func consoleDisplayTask(width, depth int, task Task, options *ViewOptions) {
-	if depth >= 0 && (!options.ShowAll && !task.CompletionTime().IsZero()) {
+func formatDueTime(task Task) {
+	if task.DueTime().IsZero() {
 		return
 	}
-	if depth >= 0 {
+	now := time.Now()
+	fmt.Fprintf(stdout, " %s%s(due %s)%s", RESET, colourDueMap[TaskDueState(task, now)],
+		FormatDueTime(task.DueTime(), now), RESET)
+}
+
+func consoleDisplayTask(width, depth int, task Task, options *ViewOptions, format *template.Template) {
+	if format != nil {
+		formatTaskTemplate(format, depth, task)
+	} else if depth >= 0 {
 		formatTask(width, depth, task, options)
 	}
 	if !options.Summarise {
 		view := CreateTaskView(task, options)
 		for i := 0; i < view.Len(); i++ {
-			consoleDisplayTask(width, depth+1, view.At(i), options)
+			consoleDisplayTask(width, depth+1, view.At(i), options, format)
 		}
 	}
 }
@@ -154,26 +217,90 @@ func NewConsoleView() *ConsoleView {
 func (c *ConsoleView) ShowTree(tasks TaskList, options *ViewOptions) {
 	width := getTerminalWidth()
 	if tasks.Title() != "" {
-		fmt.Print(TITLE_COLOUR)
+		fmt.Fprint(stdout, TITLE_COLOUR)
 		printWrappedText("    "+tasks.Title(), width, 4)
-		fmt.Printf("%s\n", RESET)
+		fmt.Fprintf(stdout, "%s\n", RESET)
+	}
+	var format *template.Template
+	if options.Format != "" {
+		var err error
+		if format, err = parseTaskFormat(options.Format); err != nil {
+			fatalf("%s", err)
+		}
 	}
 	view := CreateTaskView(tasks, options)
 	for i := 0; i < view.Len(); i++ {
-		consoleDisplayTask(width, 0, view.At(i), options)
+		consoleDisplayTask(width, 0, view.At(i), options, format)
 	}
 }
 
 func (c *ConsoleView) ShowTaskInfo(task Task) {
 	width := getTerminalWidth()
-	fmt.Print(colourPriorityMap[task.Priority()])
+	fmt.Fprint(stdout, colourPriorityMap[task.Priority()])
 	printWrappedText(task.Text(), width, 0)
-	fmt.Printf("%s\n\n", RESET)
-	fmt.Printf("%sPriority%s %s%s%s\n", BRIGHT, RESET, colourPriorityMap[task.Priority()], task.Priority().String(), RESET)
-	fmt.Printf("%sCreated:%s %s\n", BRIGHT, RESET, task.CreationTime().Local().String())
+	fmt.Fprintf(stdout, "%s\n\n", RESET)
+	if task.Note() != "" {
+		for _, line := range strings.Split(strings.TrimRight(task.Note(), "\n"), "\n") {
+			printWrappedText(line, width, 0)
+			fmt.Fprintln(stdout)
+		}
+		fmt.Fprintln(stdout)
+	}
+	fmt.Fprintf(stdout, "%sIndex:%s %s\n", BRIGHT, RESET, IndexOf(task).String())
+	fmt.Fprintf(stdout, "%sID:%s %s%d\n", BRIGHT, RESET, IDPrefix, task.ID())
+	fmt.Fprintf(stdout, "%sPriority%s %s%s%s\n", BRIGHT, RESET, colourPriorityMap[task.Priority()], task.Priority().String(), RESET)
+	fmt.Fprintf(stdout, "%sCreated:%s %s\n", BRIGHT, RESET, FormatTime(task.CreationTime()))
 	completed := "incomplete"
 	if !task.CompletionTime().IsZero() {
-		completed = task.CompletionTime().Local().String()
+		completed = FormatTime(task.CompletionTime())
+	}
+	fmt.Fprintf(stdout, "%sCompleted:%s %s\n", BRIGHT, RESET, completed)
+	if file, line, column, ok := TaskLocation(task); ok {
+		fmt.Fprintf(stdout, "%sLocation:%s %s\n", BRIGHT, RESET, FormatLocation(LocationPath(file), line, column))
+	}
+	if !task.DueTime().IsZero() {
+		fmt.Fprintf(stdout, "%sDue:%s %s%s%s\n", BRIGHT, RESET, colourDueMap[TaskDueState(task, time.Now())],
+			FormatTime(task.DueTime()), RESET)
+	}
+	if len(task.Tags()) > 0 {
+		fmt.Fprintf(stdout, "%sTags:%s %s%s%s\n", BRIGHT, RESET, TAG_COLOUR, strings.Join(task.Tags(), " "), RESET)
+	}
+	if len(task.Attributes()) > 0 {
+		fmt.Fprintf(stdout, "%sAttributes:%s\n", BRIGHT, RESET)
+		for _, key := range sortedAttributeKeys(task) {
+			fmt.Fprintf(stdout, "  %s = %s\n", key, task.Attributes()[key])
+		}
+	}
+}
+
+func (c *ConsoleView) ShowAttributes(task Task) {
+	for _, key := range sortedAttributeKeys(task) {
+		fmt.Fprintf(stdout, "%s=%s\n", key, task.Attributes()[key])
+	}
+}
+
+// Locations are shown in the "file:line:col: text" format understood by
+// editors and IDEs, without colour.
+func (c *ConsoleView) ShowLocations(tasks []Task) {
+	for _, task := range tasks {
+		file, line, column, ok := TaskLocation(task)
+		if !ok {
+			continue
+		}
+		file = LocationPath(file)
+		text := task.Text()
+		if marker := task.Attributes()[MarkerAttribute]; marker != "" {
+			text = marker + ": " + text
+		}
+		fmt.Fprintf(stdout, "%s: %s [%s%d]\n", FormatLocation(file, line, column), text, IDPrefix, task.ID())
+	}
+}
+
+func sortedAttributeKeys(task Task) []string {
+	keys := make([]string, 0, len(task.Attributes()))
+	for key := range task.Attributes() {
+		keys = append(keys, key)
 	}
-	fmt.Printf("%sCompleted:%s %s\n", BRIGHT, RESET, completed)
+	sort.Strings(keys)
+	return keys
 }
@@ -0,0 +1,77 @@
+/*
+  Copyright 2011 Alec Thomas
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+*/
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"path/filepath"
+	"testing"
+)
+
+func TestConsoleViewCompletedParent(t *testing.T) {
+	defer func(saved io.Writer) { stdout = saved }(stdout)
+	tasks := NewTaskList()
+	parent := tasks.Create("parent", MEDIUM)
+	parent.Create("open child", MEDIUM)
+	parent.Create("done child", MEDIUM).SetCompleted()
+	parent.SetCompleted()
+	tasks.Create("open parent", MEDIUM).Create("child", MEDIUM)
+
+	out := &bytes.Buffer{}
+	stdout = &ansiStripper{w: out}
+	NewConsoleView().ShowTree(tasks, &ViewOptions{Order: INDEX, Filter: NotDone})
+	expected := "- 1.parent\n" +
+		"      1.open child\n" +
+		"+ 2.open parent\n" +
+		"      1.child\n"
+	if out.String() != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, out)
+	}
+}
+
+func TestShowLocations(t *testing.T) {
+	defer func(saved io.Writer) { stdout = saved }(stdout)
+	defer func(saved string) { *fileFlag = saved }(*fileFlag)
+	*fileFlag = filepath.Join("project", ".todo2")
+	tasks := NewTaskList()
+	relative := tasks.Create("fix it", MEDIUM)
+	setImportedAttributes(relative, filepath.Join("src", "a.go"), importedItem{Line: 2, Column: 4, Marker: "TODO", Text: "fix it"})
+	absolute := tasks.Create("handle errors", MEDIUM)
+	setImportedAttributes(absolute, "/abs/b.go", importedItem{Marker: "FIXME", Text: "handle errors"})
+
+	out := &bytes.Buffer{}
+	stdout = &ansiStripper{w: out}
+	NewConsoleView().ShowLocations([]Task{relative, absolute})
+	expected := filepath.Join("project", "src", "a.go") + ":2:4: TODO: fix it [@1]\n" +
+		"/abs/b.go:1:1: FIXME: handle errors [@2]\n"
+	if out.String() != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, out)
+	}
+
+	out.Reset()
+	stdout = out
+	NewJSONView(true).ShowLocations([]Task{relative})
+	location := map[string]interface{}{}
+	if err := json.Unmarshal(out.Bytes(), &location); err != nil {
+		t.Fatal(err)
+	}
+	if location["file"] != filepath.Join("project", "src", "a.go") || location["line"] != float64(2) {
+		t.Errorf("unexpected location %v", location)
+	}
+}
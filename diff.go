@@ -0,0 +1,189 @@
+/*
+  Copyright 2011 Alec Thomas
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+*/
+
+// Importing TODO items from the lines added by a unified diff.
+
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// The new side of a hunk: context and added lines, in order.
+type diffHunk struct {
+	File  string
+	Start int      // Line number of the first line in the new file.
+	Lines []string // Lines of the new side of the hunk.
+	Added []bool   // Whether each line was added.
+}
+
+var hunkHeaderPattern = regexp.MustCompile(`^@@ -(\d+)(?:,(\d+))? \+(\d+)(?:,(\d+))? @@`)
+
+// Parse the hunks of a unified diff, such as produced by "git diff" or
+// "diff -u". Hunks for deleted files are omitted.
+func parseUnifiedDiff(reader io.Reader) ([]*diffHunk, error) {
+	hunks := []*diffHunk{}
+	file := ""
+	var hunk *diffHunk
+	oldRemaining, newRemaining := 0, 0
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(nil, 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if hunk != nil && (oldRemaining > 0 || newRemaining > 0) {
+			switch {
+			case strings.HasPrefix(line, "+"):
+				hunk.Lines = append(hunk.Lines, line[1:])
+				hunk.Added = append(hunk.Added, true)
+				newRemaining--
+			case strings.HasPrefix(line, "-"):
+				oldRemaining--
+			case strings.HasPrefix(line, `\`):
+				// "\ No newline at end of file"
+			default:
+				hunk.Lines = append(hunk.Lines, strings.TrimPrefix(line, " "))
+				hunk.Added = append(hunk.Added, false)
+				oldRemaining--
+				newRemaining--
+			}
+			continue
+		}
+		switch {
+		case strings.HasPrefix(line, "+++ "):
+			file = diffPath(line[4:])
+		case strings.HasPrefix(line, "@@ "):
+			match := hunkHeaderPattern.FindStringSubmatch(line)
+			if match == nil {
+				return nil, fmt.Errorf("invalid hunk header '%s'", line)
+			}
+			if file == "" {
+				return nil, fmt.Errorf("hunk without a file header")
+			}
+			oldRemaining, newRemaining = 1, 1
+			if match[2] != "" {
+				oldRemaining, _ = strconv.Atoi(match[2])
+			}
+			if match[4] != "" {
+				newRemaining, _ = strconv.Atoi(match[4])
+			}
+			start, _ := strconv.Atoi(match[3])
+			hunk = &diffHunk{File: file, Start: start}
+			if file != "/dev/null" {
+				hunks = append(hunks, hunk)
+			}
+		}
+	}
+	return hunks, scanner.Err()
+}
+
+// Strip the "b/" prefix and any timestamp from a "+++" file header.
+func diffPath(header string) string {
+	if tab := strings.IndexByte(header, '\t'); tab != -1 {
+		header = header[:tab]
+	}
+	header = strings.TrimSpace(header)
+	if header == "/dev/null" {
+		return header
+	}
+	return filepath.Clean(strings.TrimPrefix(header, "b/"))
+}
+
+// Extract TODO items from the lines added by a hunk. The hunk's context is
+// scanned too, so that comments are recognised as well as the limited
+// context allows.
+func extractHunkItems(hunk *diffHunk, markers *importMarkers) []importedItem {
+	var items []importedItem
+	if syntax := commentSyntaxForFile(hunk.File); syntax != nil {
+		items = extractCommentItems(scanComments(strings.Join(hunk.Lines, "\n"), syntax), markers)
+	} else {
+		for i, line := range hunk.Lines {
+			if item, ok := markers.match(i+1, 1, line); ok {
+				items = append(items, item)
+			}
+		}
+	}
+	added := []importedItem{}
+	for _, item := range items {
+		if hunk.Added[item.Line-1] {
+			item.Line += hunk.Start - 1
+			added = append(added, item)
+		}
+	}
+	return added
+}
+
+// Add tasks for items introduced by a diff. Unlike a full import this never
+// completes tasks, and an item that already has a task just updates its
+// location, so importing the same diff again is harmless.
+func addImportedItems(tasks TaskList, parent TaskNode, file string, items []importedItem) {
+	for _, item := range items {
+		matches := tasks.FindAll(func(task Task) bool {
+			return task.Attributes()[FileAttribute] == file && task.Text() == item.Text
+		})
+		var task Task
+		if len(matches) > 0 {
+			task = matches[0]
+		} else {
+			task = parent.Create(item.Text, item.Priority)
+		}
+		setImportedAttributes(task, file, item)
+	}
+}
+
+func doImportDiff(tasks TaskList, graft TaskNode, args []string, options *importOptions) {
+	reader := io.Reader(os.Stdin)
+	if len(args) > 0 && args[0] != "-" {
+		file, err := os.Open(args[0])
+		if err != nil {
+			fatalf("failed to open %s: %s", args[0], err)
+		}
+		defer file.Close()
+		reader = file
+	}
+	hunks, err := parseUnifiedDiff(reader)
+	if err != nil {
+		fatalf("failed to parse diff: %s", err)
+	}
+	// Paths in diffs from git are relative to the root of the repository.
+	base := "."
+	if cwd, err := os.Getwd(); err == nil {
+		if root, ok := repositoryRoot(cwd); ok {
+			base = root
+		}
+	}
+	for _, hunk := range hunks {
+		items := extractHunkItems(hunk, options.Markers)
+		if len(items) == 0 {
+			continue
+		}
+		options.prioritise(items)
+		file := hunk.File
+		if !filepath.IsAbs(file) {
+			file = filepath.Join(base, file)
+		}
+		file = options.recordedPath(file)
+		addImportedItems(tasks, importGroup(tasks, graft, file, options.Group), file, items)
+	}
+	updateImportGroups(tasks)
+	saveTaskList(tasks)
+}
@@ -0,0 +1,164 @@
+/*
+  Copyright 2011 Alec Thomas
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+*/
+
+// Parsing and classification of task due times.
+
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Tasks due within this window are considered "due soon".
+const dueSoonWindow = 48 * time.Hour
+
+type DueState int
+
+// DueState constants.
+const (
+	NOTDUE = DueState(iota)
+	DUESOON
+	OVERDUE
+)
+
+// Colour configuration names for due states.
+var dueStateFromString = map[string]DueState{
+	"due":     NOTDUE,
+	"duesoon": DUESOON,
+	"overdue": OVERDUE,
+}
+
+// Layout used to display dates.
+var dateLayout = "2006-01-02"
+
+var weekdayFromString = map[string]time.Weekday{
+	"sun":       time.Sunday,
+	"sunday":    time.Sunday,
+	"mon":       time.Monday,
+	"monday":    time.Monday,
+	"tue":       time.Tuesday,
+	"tuesday":   time.Tuesday,
+	"wed":       time.Wednesday,
+	"wednesday": time.Wednesday,
+	"thu":       time.Thursday,
+	"thursday":  time.Thursday,
+	"fri":       time.Friday,
+	"friday":    time.Friday,
+	"sat":       time.Saturday,
+	"saturday":  time.Saturday,
+}
+
+var dueLayouts = []string{
+	"2006-01-02",
+	"2006-01-02 15:04",
+	"2006-01-02T15:04",
+}
+
+// End of the day containing t, in t's location. Date-only due times are
+// stored as the last second of the day so they only become overdue once the
+// day has passed.
+func endOfDay(t time.Time) time.Time {
+	year, month, day := t.Date()
+	return time.Date(year, month, day, 23, 59, 59, 0, t.Location())
+}
+
+// ParseDueTime converts user input such as "today", "tomorrow", "fri",
+// "2026-11-03" or "+3d" into an absolute time relative to now. Offsets are in
+// hours, days, weeks, months or years: "+1m" is a month, as in filter
+// expressions, not a minute.
+func ParseDueTime(text string, now time.Time) (time.Time, error) {
+	text = strings.ToLower(strings.TrimSpace(text))
+	switch text {
+	case "today":
+		return endOfDay(now), nil
+	case "tomorrow":
+		return endOfDay(now.AddDate(0, 0, 1)), nil
+	case "yesterday":
+		return endOfDay(now.AddDate(0, 0, -1)), nil
+	}
+	if weekday, ok := weekdayFromString[text]; ok {
+		days := (int(weekday) - int(now.Weekday()) + 7) % 7
+		if days == 0 {
+			days = 7
+		}
+		return endOfDay(now.AddDate(0, 0, days)), nil
+	}
+	if strings.HasPrefix(text, "+") && len(text) > 2 {
+		count, err := strconv.Atoi(text[1 : len(text)-1])
+		if err == nil && count >= 0 {
+			switch text[len(text)-1] {
+			case 'h':
+				return now.Add(time.Duration(count) * time.Hour), nil
+			case 'd':
+				return endOfDay(now.AddDate(0, 0, count)), nil
+			case 'w':
+				return endOfDay(now.AddDate(0, 0, count*7)), nil
+			case 'm':
+				return endOfDay(now.AddDate(0, count, 0)), nil
+			case 'y':
+				return endOfDay(now.AddDate(count, 0, 0)), nil
+			}
+		}
+	}
+	for i, layout := range dueLayouts {
+		if due, err := time.ParseInLocation(layout, text, now.Location()); err == nil {
+			if i == 0 {
+				due = endOfDay(due)
+			}
+			return due, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid due date '%s'", text)
+}
+
+// FormatDueTime returns a short human readable representation of due.
+func FormatDueTime(due time.Time, now time.Time) string {
+	due = due.In(now.Location())
+	day := due.Format(dateLayout)
+	switch due.Format("2006-01-02") {
+	case now.Format("2006-01-02"):
+		day = "today"
+	case now.AddDate(0, 0, 1).Format("2006-01-02"):
+		day = "tomorrow"
+	}
+	if !due.Equal(endOfDay(due)) {
+		day += due.Format(" 15:04")
+	}
+	return day
+}
+
+// FormatTime formats a date and time for display in the local time zone.
+func FormatTime(t time.Time) string {
+	return t.Local().Format(dateLayout + " 15:04:05")
+}
+
+// TaskDueState classifies an incomplete task by how close it is to being due.
+func TaskDueState(task Task, now time.Time) DueState {
+	due := task.DueTime()
+	if due.IsZero() || !task.CompletionTime().IsZero() {
+		return NOTDUE
+	}
+	if now.After(due) {
+		return OVERDUE
+	}
+	if due.Sub(now) <= dueSoonWindow {
+		return DUESOON
+	}
+	return NOTDUE
+}
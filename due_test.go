@@ -0,0 +1,68 @@
+/*
+  Copyright 2011 Alec Thomas
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+*/
+
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseDueTime(t *testing.T) {
+	// A Wednesday.
+	now := time.Date(2026, 10, 14, 10, 30, 0, 0, time.UTC)
+	expected := map[string]time.Time{
+		"today":            time.Date(2026, 10, 14, 23, 59, 59, 0, time.UTC),
+		"tomorrow":         time.Date(2026, 10, 15, 23, 59, 59, 0, time.UTC),
+		"fri":              time.Date(2026, 10, 16, 23, 59, 59, 0, time.UTC),
+		"Wednesday":        time.Date(2026, 10, 21, 23, 59, 59, 0, time.UTC),
+		"2026-11-03":       time.Date(2026, 11, 3, 23, 59, 59, 0, time.UTC),
+		"2026-11-03 09:15": time.Date(2026, 11, 3, 9, 15, 0, 0, time.UTC),
+		"+3d":              time.Date(2026, 10, 17, 23, 59, 59, 0, time.UTC),
+		"+2h":              time.Date(2026, 10, 14, 12, 30, 0, 0, time.UTC),
+		"+1m":              time.Date(2026, 11, 14, 23, 59, 59, 0, time.UTC),
+	}
+	for text, want := range expected {
+		got, err := ParseDueTime(text, now)
+		if err != nil || !got.Equal(want) {
+			t.Errorf("ParseDueTime(%q) = %s, %v; expected %s", text, got, err, want)
+		}
+	}
+	if _, err := ParseDueTime("someday", now); err == nil {
+		t.Error("expected error for invalid due date")
+	}
+}
+
+func TestOrderByDue(t *testing.T) {
+	now := time.Now()
+	tasks := NewTaskList()
+	tasks.Create("later", MEDIUM).SetDueTime(now.AddDate(0, 0, 3))
+	tasks.Create("never", MEDIUM)
+	tasks.Create("soon", MEDIUM).SetDueTime(now.Add(time.Hour))
+	tasks.Create("sometime", MEDIUM).SetDueTime(now.AddDate(0, 1, 0))
+	for _, name := range []string{"due", "deadline"} {
+		order, reversed := OrderFromString(name)
+		view := CreateTaskView(tasks, &ViewOptions{Order: order, Reversed: reversed})
+		texts := []string{}
+		for i := 0; i < view.Len(); i++ {
+			texts = append(texts, view.At(i).Text())
+		}
+		if strings.Join(texts, ",") != "soon,later,sometime,never" {
+			t.Errorf("--order %s: unexpected order %v", name, texts)
+		}
+	}
+}
@@ -0,0 +1,64 @@
+/*
+  Copyright 2011 Alec Thomas
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+*/
+
+// Editing text with the user's editor.
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// The user's editor, from $VISUAL or $EDITOR.
+func editorCommand() string {
+	for _, variable := range []string{"VISUAL", "EDITOR"} {
+		if editor := strings.TrimSpace(os.Getenv(variable)); editor != "" {
+			return editor
+		}
+	}
+	return "vi"
+}
+
+// Edit text in the user's editor, returning the edited text. The temporary
+// file is given pattern (see os.CreateTemp) so that editors can recognise its
+// type.
+func editText(text, pattern string) (string, error) {
+	file, err := os.CreateTemp("", pattern)
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(file.Name())
+	_, err = file.WriteString(text)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return "", err
+	}
+	// Run via the shell so that the editor may include arguments.
+	cmd := exec.Command("/bin/sh", "-c", editorCommand()+` "$@"`, "--", file.Name())
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err = cmd.Run(); err != nil {
+		return "", fmt.Errorf("editor %s failed: %s", editorCommand(), err)
+	}
+	data, err := os.ReadFile(file.Name())
+	return string(data), err
+}
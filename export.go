@@ -0,0 +1,103 @@
+/*
+  Copyright 2011 Alec Thomas
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+*/
+
+// Exporting and importing task lists in other formats.
+
+package main
+
+import (
+	"io"
+	"os"
+	"sort"
+)
+
+// Task list formats usable with --export and --import-format.
+var taskListFormats = map[string]func() TaskListIO{
+	"json":    NewJSONIO,
+	"md":      NewMarkdownIO,
+	"todotxt": NewTodoTxtIO,
+}
+
+func taskListFormatNames() []string {
+	names := []string{}
+	for name := range taskListFormats {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Write the task list to a file, or to stdout if no file is given.
+func doExport(tasks TaskList, format string, args []string) {
+	writer := io.Writer(os.Stdout)
+	if len(args) > 0 {
+		file, err := os.Create(args[0])
+		if err != nil {
+			fatalf("%s", err)
+		}
+		defer file.Close()
+		writer = file
+	}
+	if err := taskListFormats[format]().Serialize(writer, tasks); err != nil {
+		fatalf("failed to export: %s", err)
+	}
+}
+
+// Add tasks from task list files, or stdin if no files are given, below
+// graft. Imported tasks are given new IDs.
+func doImportTaskList(tasks TaskList, graft TaskNode, format string, args []string) {
+	readers := []io.Reader{}
+	for _, arg := range args {
+		file, err := os.Open(arg)
+		if err != nil {
+			fatalf("%s", err)
+		}
+		defer file.Close()
+		readers = append(readers, file)
+	}
+	if len(readers) == 0 {
+		readers = append(readers, os.Stdin)
+	}
+	for _, reader := range readers {
+		imported, err := taskListFormats[format]().Deserialize(reader)
+		if err != nil {
+			fatalf("failed to import: %s", err)
+		}
+		if tasks.Title() == "" {
+			tasks.SetTitle(imported.Title())
+		}
+		for i := 0; i < imported.Len(); i++ {
+			copyTask(graft, imported.At(i))
+		}
+	}
+	saveTaskList(tasks)
+}
+
+// Copy task and its descendants below parent.
+func copyTask(parent TaskNode, task Task) {
+	copied := parent.Create(task.Text(), task.Priority())
+	copied.SetNote(task.Note())
+	copied.SetCreationTime(task.CreationTime())
+	copied.SetCompletionTime(task.CompletionTime())
+	copied.SetDueTime(task.DueTime())
+	copied.SetTags(append([]string{}, task.Tags()...))
+	for key, value := range task.Attributes() {
+		copied.Attributes()[key] = value
+	}
+	for i := 0; i < task.Len(); i++ {
+		copyTask(copied, task.At(i))
+	}
+}
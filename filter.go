@@ -0,0 +1,420 @@
+/*
+  Copyright 2011 Alec Thomas
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+*/
+
+// A small expression language for selecting tasks, eg.
+//
+//   priority>=high and not done and text~"parser" and created<7d
+//
+// Expressions are made of comparisons and flags combined with "and", "or",
+// "not" and parentheses. Comparisons are of the form <field> <op> <value>
+// where <op> is one of = != < <= > >= ~ (regular expression match) or !~.
+//
+//   priority     veryhigh, high, medium, low or verylow; "higher" is greater
+//   text         the task text
+//   tag          a tag, with or without its sigil
+//   id           the task ID
+//   created      a date (2026-11-03) or an age (7d, 2w, 3m); "<7d" is "less
+//   completed    than seven days ago"
+//   due          a date or a duration from now; "due<3d" is "due within three
+//                days"
+//   attr.<key>   the value of an attribute
+//
+// Flags are "done", "overdue", "due" (has a due date) and "tagged".
+
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Predicate selects tasks.
+type Predicate func(task Task) bool
+
+// And combines predicates, ignoring nil entries. The result is nil if all
+// predicates are nil.
+func And(predicates ...Predicate) Predicate {
+	active := []Predicate{}
+	for _, predicate := range predicates {
+		if predicate != nil {
+			active = append(active, predicate)
+		}
+	}
+	if len(active) == 0 {
+		return nil
+	}
+	return func(task Task) bool {
+		for _, predicate := range active {
+			if !predicate(task) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// NotDone selects incomplete tasks.
+func NotDone(task Task) bool {
+	return task.CompletionTime().IsZero()
+}
+
+// WithTags selects tasks with any of the given tags.
+func WithTags(tags []string) Predicate {
+	if len(tags) == 0 {
+		return nil
+	}
+	return func(task Task) bool {
+		return HasAnyTag(task, tags)
+	}
+}
+
+// CompileFilter compiles a filter expression into a Predicate, evaluating
+// relative times against now.
+func CompileFilter(expr string, now time.Time) (Predicate, error) {
+	tokens, err := tokeniseFilter(expr)
+	if err != nil {
+		return nil, err
+	}
+	p := &filterParser{tokens: tokens, now: now}
+	predicate, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.tokens) {
+		return nil, fmt.Errorf("filter: unexpected '%s'", p.tokens[p.pos].text)
+	}
+	return predicate, nil
+}
+
+type filterTokenKind int
+
+const (
+	filterWord = filterTokenKind(iota)
+	filterString
+	filterOperator
+	filterLParen
+	filterRParen
+)
+
+type filterToken struct {
+	kind filterTokenKind
+	text string
+}
+
+const filterOperatorChars = "=!<>~"
+
+func tokeniseFilter(expr string) ([]filterToken, error) {
+	tokens := []filterToken{}
+	for i := 0; i < len(expr); {
+		c := expr[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n':
+			i++
+		case c == '(':
+			tokens = append(tokens, filterToken{filterLParen, "("})
+			i++
+		case c == ')':
+			tokens = append(tokens, filterToken{filterRParen, ")"})
+			i++
+		case c == '"' || c == '\'':
+			end := strings.IndexByte(expr[i+1:], c)
+			if end == -1 {
+				return nil, fmt.Errorf("filter: unterminated string")
+			}
+			tokens = append(tokens, filterToken{filterString, expr[i+1 : i+1+end]})
+			i += end + 2
+		case strings.IndexByte(filterOperatorChars, c) != -1:
+			start := i
+			for i < len(expr) && strings.IndexByte(filterOperatorChars, expr[i]) != -1 {
+				i++
+			}
+			tokens = append(tokens, filterToken{filterOperator, expr[start:i]})
+		default:
+			start := i
+			for i < len(expr) && strings.IndexByte(" \t\n()\"'"+filterOperatorChars, expr[i]) == -1 {
+				i++
+			}
+			tokens = append(tokens, filterToken{filterWord, expr[start:i]})
+		}
+	}
+	return tokens, nil
+}
+
+type filterParser struct {
+	tokens []filterToken
+	pos    int
+	now    time.Time
+}
+
+func (p *filterParser) peek() *filterToken {
+	if p.pos >= len(p.tokens) {
+		return nil
+	}
+	return &p.tokens[p.pos]
+}
+
+func (p *filterParser) acceptKeyword(keyword string) bool {
+	if token := p.peek(); token != nil && token.kind == filterWord && strings.EqualFold(token.text, keyword) {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *filterParser) parseOr() (Predicate, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.acceptKeyword("or") {
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		l := left
+		left = func(task Task) bool { return l(task) || right(task) }
+	}
+	return left, nil
+}
+
+func (p *filterParser) parseAnd() (Predicate, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for p.acceptKeyword("and") {
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		l := left
+		left = func(task Task) bool { return l(task) && right(task) }
+	}
+	return left, nil
+}
+
+func (p *filterParser) parseUnary() (Predicate, error) {
+	if p.acceptKeyword("not") {
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return func(task Task) bool { return !operand(task) }, nil
+	}
+	return p.parsePrimary()
+}
+
+func (p *filterParser) parsePrimary() (Predicate, error) {
+	token := p.peek()
+	if token == nil {
+		return nil, fmt.Errorf("filter: unexpected end of expression")
+	}
+	p.pos++
+	switch token.kind {
+	case filterLParen:
+		predicate, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if next := p.peek(); next == nil || next.kind != filterRParen {
+			return nil, fmt.Errorf("filter: expected ')'")
+		}
+		p.pos++
+		return predicate, nil
+	case filterWord:
+		field := strings.ToLower(token.text)
+		if next := p.peek(); next != nil && next.kind == filterOperator {
+			p.pos++
+			value := p.peek()
+			if value == nil || (value.kind != filterWord && value.kind != filterString) {
+				return nil, fmt.Errorf("filter: expected value after %s%s", token.text, next.text)
+			}
+			p.pos++
+			return p.comparison(field, next.text, value.text)
+		}
+		return p.flag(field)
+	}
+	return nil, fmt.Errorf("filter: unexpected '%s'", token.text)
+}
+
+func (p *filterParser) flag(name string) (Predicate, error) {
+	switch name {
+	case "done":
+		return func(task Task) bool { return !task.CompletionTime().IsZero() }, nil
+	case "overdue":
+		now := p.now
+		return func(task Task) bool { return TaskDueState(task, now) == OVERDUE }, nil
+	case "due":
+		return func(task Task) bool { return !task.DueTime().IsZero() }, nil
+	case "tagged":
+		return func(task Task) bool { return len(task.Tags()) > 0 }, nil
+	}
+	return nil, fmt.Errorf("filter: unknown flag '%s'", name)
+}
+
+func (p *filterParser) comparison(field, op, value string) (Predicate, error) {
+	switch {
+	case field == "priority":
+		if _, ok := priorityMapFromString[strings.ToLower(value)]; !ok {
+			return nil, fmt.Errorf("filter: invalid priority '%s'", value)
+		}
+		// Lower Priority values are more important, so negate to compare.
+		expected := -int(PriorityFromString(strings.ToLower(value)))
+		return compareInts(op, expected, func(task Task) int { return -int(task.Priority()) })
+	case field == "id":
+		expected, err := strconv.Atoi(strings.TrimPrefix(value, IDPrefix))
+		if err != nil {
+			return nil, fmt.Errorf("filter: invalid id '%s'", value)
+		}
+		return compareInts(op, expected, func(task Task) int { return task.ID() })
+	case field == "text":
+		return compareStrings(op, value, func(task Task) []string { return []string{task.Text()} })
+	case field == "tag":
+		if op == "=" || op == "!=" {
+			predicate := func(task Task) bool { return HasAnyTag(task, []string{value}) }
+			if op == "!=" {
+				return func(task Task) bool { return !predicate(task) }, nil
+			}
+			return predicate, nil
+		}
+		return compareStrings(op, value, func(task Task) []string { return task.Tags() })
+	case strings.HasPrefix(field, "attr."):
+		key := field[len("attr."):]
+		return compareStrings(op, value, func(task Task) []string {
+			if v, ok := task.Attributes()[key]; ok {
+				return []string{v}
+			}
+			return nil
+		})
+	case field == "created":
+		return p.compareTimes(op, value, false, func(task Task) time.Time { return task.CreationTime() })
+	case field == "completed":
+		return p.compareTimes(op, value, false, func(task Task) time.Time { return task.CompletionTime() })
+	case field == "due":
+		return p.compareTimes(op, value, true, func(task Task) time.Time { return task.DueTime() })
+	}
+	return nil, fmt.Errorf("filter: unknown field '%s'", field)
+}
+
+func compareInts(op string, expected int, get func(task Task) int) (Predicate, error) {
+	var compare func(a, b int) bool
+	switch op {
+	case "=":
+		compare = func(a, b int) bool { return a == b }
+	case "!=":
+		compare = func(a, b int) bool { return a != b }
+	case "<":
+		compare = func(a, b int) bool { return a < b }
+	case "<=":
+		compare = func(a, b int) bool { return a <= b }
+	case ">":
+		compare = func(a, b int) bool { return a > b }
+	case ">=":
+		compare = func(a, b int) bool { return a >= b }
+	default:
+		return nil, fmt.Errorf("filter: invalid operator '%s'", op)
+	}
+	return func(task Task) bool { return compare(get(task), expected) }, nil
+}
+
+// Compares each of the values returned by get against expected, matching if
+// any of them do (or, for negated operators, if none of them do).
+func compareStrings(op, expected string, get func(task Task) []string) (Predicate, error) {
+	var match func(value string) bool
+	negate := false
+	switch op {
+	case "=", "!=":
+		match = func(value string) bool { return value == expected }
+		negate = op == "!="
+	case "~", "!~":
+		re, err := regexp.Compile("(?i)" + expected)
+		if err != nil {
+			return nil, fmt.Errorf("filter: invalid regular expression '%s': %s", expected, err)
+		}
+		match = re.MatchString
+		negate = op == "!~"
+	default:
+		return nil, fmt.Errorf("filter: invalid operator '%s'", op)
+	}
+	return func(task Task) bool {
+		for _, value := range get(task) {
+			if match(value) {
+				return !negate
+			}
+		}
+		return negate
+	}, nil
+}
+
+var filterAgePattern = regexp.MustCompile(`^(\d+)([hdwmy])$`)
+
+// Compares a time field against either an absolute date or a duration
+// relative to now. Durations are ages for past events ("created<7d" means
+// created less than seven days ago) and offsets for future ones ("due<3d"
+// means due in less than three days). Tasks where the field is unset never
+// match.
+func (p *filterParser) compareTimes(op, value string, future bool, get func(task Task) time.Time) (Predicate, error) {
+	var threshold time.Time
+	if match := filterAgePattern.FindStringSubmatch(value); match != nil {
+		count, _ := strconv.Atoi(match[1])
+		if !future {
+			count = -count
+		}
+		switch match[2] {
+		case "h":
+			threshold = p.now.Add(time.Duration(count) * time.Hour)
+		case "d":
+			threshold = p.now.AddDate(0, 0, count)
+		case "w":
+			threshold = p.now.AddDate(0, 0, count*7)
+		case "m":
+			threshold = p.now.AddDate(0, count, 0)
+		case "y":
+			threshold = p.now.AddDate(count, 0, 0)
+		}
+		if !future {
+			// An age less than N is a time after now-N.
+			inverted, ok := map[string]string{"<": ">", "<=": ">=", ">": "<", ">=": "<=", "=": "=", "!=": "!="}[op]
+			if !ok {
+				return nil, fmt.Errorf("filter: invalid operator '%s'", op)
+			}
+			op = inverted
+		}
+	} else {
+		due, err := ParseDueTime(value, p.now)
+		if err != nil {
+			return nil, fmt.Errorf("filter: invalid time '%s'", value)
+		}
+		threshold = due
+	}
+	// Whole days (eg. "2026-11-03", "today") are compared by date alone.
+	key := func(t time.Time) string { return t.UTC().Format(time.RFC3339) }
+	if threshold.Equal(endOfDay(threshold)) {
+		location := p.now.Location()
+		key = func(t time.Time) string { return t.In(location).Format("2006-01-02") }
+	}
+	expected := key(threshold)
+	predicate, err := compareInts(op, 0, func(task Task) int {
+		return strings.Compare(key(get(task)), expected)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return func(task Task) bool { return !get(task).IsZero() && predicate(task) }, nil
+}
@@ -0,0 +1,59 @@
+/*
+  Copyright 2011 Alec Thomas
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+*/
+
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCompileFilter(t *testing.T) {
+	now := time.Date(2026, 10, 14, 10, 30, 0, 0, time.UTC)
+	tasks := NewTaskList()
+	a := tasks.Create("fix the parser", HIGH)
+	a.SetCreationTime(now.AddDate(0, 0, -2))
+	b := tasks.Create("write docs", LOW)
+	b.SetCreationTime(now.AddDate(0, 0, -30))
+	b.SetTags([]string{"+docs"})
+	c := tasks.Create("old parser bug", VERYHIGH)
+	c.SetCreationTime(now.AddDate(0, 0, -1))
+	c.SetCompletionTime(now)
+
+	expected := map[string][]Task{
+		`priority>=high and not done and text~"PARSER" and created<7d`: {a},
+		`priority<medium or tag=docs`:                                  {b},
+		`done`:                                                         {c},
+		`not (done or tagged) and created=2026-10-12`:                  {a},
+		`created>1w`:                                                   {b},
+	}
+	for expr, want := range expected {
+		predicate, err := CompileFilter(expr, now)
+		if err != nil {
+			t.Errorf("%s: %s", expr, err)
+			continue
+		}
+		got := tasks.FindAll(predicate)
+		if len(got) != len(want) || (len(got) > 0 && got[0] != want[0]) {
+			t.Errorf("%s: expected %v, got %v", expr, want, got)
+		}
+	}
+	for _, expr := range []string{"priority>=", "colour=red", "(done", "text<foo"} {
+		if _, err := CompileFilter(expr, now); err == nil {
+			t.Errorf("%s: expected error", expr)
+		}
+	}
+}
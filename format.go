@@ -0,0 +1,144 @@
+/*
+  Copyright 2011 Alec Thomas
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+*/
+
+// Custom task formats for the console view, using text/template.
+
+package main
+
+import (
+	"fmt"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// Fields available to task format templates.
+type taskFormatData struct {
+	Index      string // Dotted index, eg. "1.2".
+	Number     int    // One-based position below the parent.
+	ID         int
+	Depth      int
+	Indent     string // Four spaces per level of depth.
+	State      string // "-" if done, otherwise "+" if the task has children.
+	Done       bool
+	Priority   string
+	Text       string
+	Note       string
+	Tags       string
+	Attributes string // Sorted key=value pairs.
+	Created    string
+	Completed  string
+	Age        string // Time since creation, eg. "3d".
+	Duration   string // Time from creation to completion, if done.
+	Due        string
+	DueState   string // due, duesoon or overdue, or empty if not due.
+	Task       Task
+}
+
+var taskFormatFuncs = template.FuncMap{
+	// Colour of a display element, priority or due state.
+	"colour": elementColour,
+	"color":  elementColour,
+	"reset":  func() string { return RESET },
+}
+
+func elementColour(element string) string {
+	if colour, ok := colourElements[element]; ok {
+		return *colour
+	}
+	if priority, ok := priorityMapFromString[element]; ok {
+		return colourPriorityMap[priority]
+	}
+	if state, ok := dueStateFromString[element]; ok {
+		return colourDueMap[state]
+	}
+	return ""
+}
+
+func parseTaskFormat(format string) (*template.Template, error) {
+	tmpl, err := template.New("format").Funcs(taskFormatFuncs).Parse(format)
+	if err != nil {
+		return nil, fmt.Errorf("invalid format: %s", err)
+	}
+	return tmpl, nil
+}
+
+// Format a duration approximately, in the units used by filter expressions.
+func formatAge(d time.Duration) string {
+	day := 24 * time.Hour
+	switch {
+	case d < day:
+		return fmt.Sprintf("%dh", d/time.Hour)
+	case d < 14*day:
+		return fmt.Sprintf("%dd", d/day)
+	case d < 8*7*day:
+		return fmt.Sprintf("%dw", d/(7*day))
+	case d < 365*day:
+		return fmt.Sprintf("%dm", d/(30*day))
+	}
+	return fmt.Sprintf("%dy", d/(365*day))
+}
+
+func newTaskFormatData(task Task, depth int, now time.Time) *taskFormatData {
+	data := &taskFormatData{
+		Index:    IndexOf(task).String(),
+		Number:   Position(task) + 1,
+		ID:       task.ID(),
+		Depth:    depth,
+		Indent:   strings.Repeat("    ", depth),
+		State:    strings.TrimSpace(string(rune(taskState(task)))),
+		Done:     !task.CompletionTime().IsZero(),
+		Priority: task.Priority().String(),
+		Text:     task.Text(),
+		Note:     task.Note(),
+		Tags:     strings.Join(task.Tags(), " "),
+		Created:  FormatTime(task.CreationTime()),
+		Age:      formatAge(now.Sub(task.CreationTime())),
+		Task:     task,
+	}
+	attributes := []string{}
+	for _, key := range sortedAttributeKeys(task) {
+		attributes = append(attributes, key+"="+task.Attributes()[key])
+	}
+	data.Attributes = strings.Join(attributes, " ")
+	if data.Done {
+		data.Completed = FormatTime(task.CompletionTime())
+		data.Duration = formatAge(task.CompletionTime().Sub(task.CreationTime()))
+	}
+	if !task.DueTime().IsZero() {
+		data.Due = FormatDueTime(task.DueTime(), now)
+		for name, state := range dueStateFromString {
+			if state == TaskDueState(task, now) {
+				data.DueState = name
+			}
+		}
+	}
+	return data
+}
+
+// Render a task with a format template, adding a trailing newline if the
+// template does not end with one.
+func formatTaskTemplate(tmpl *template.Template, depth int, task Task) {
+	out := &strings.Builder{}
+	if err := tmpl.Execute(out, newTaskFormatData(task, depth, time.Now())); err != nil {
+		fatalf("invalid format: %s", err)
+	}
+	line := out.String()
+	if !strings.HasSuffix(line, "\n") {
+		line += "\n"
+	}
+	fmt.Fprint(stdout, line)
+}
@@ -0,0 +1,68 @@
+/*
+  Copyright 2011 Alec Thomas
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+*/
+
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTaskFormat(t *testing.T) {
+	now := time.Date(2026, 3, 10, 12, 0, 0, 0, time.Local)
+	tasks := NewTaskList()
+	parent := tasks.Create("parent", MEDIUM)
+	task := parent.Create("child", HIGH)
+	task.SetCreationTime(now.Add(-72 * time.Hour))
+	task.SetCompletionTime(now.Add(-24 * time.Hour))
+	task.SetDueTime(endOfDay(now))
+	task.SetTags([]string{"+backend"})
+	task.Attributes()["b"] = "2"
+	task.Attributes()["a"] = "1"
+
+	tmpl, err := parseTaskFormat(`{{.Indent}}{{.Index}} @{{.ID}} {{.State}} {{.Priority}} {{.Text}} {{.Tags}} {{.Attributes}} {{.Age}} {{.Duration}} {{.Due}}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := &strings.Builder{}
+	if err = tmpl.Execute(out, newTaskFormatData(task, 1, now)); err != nil {
+		t.Fatal(err)
+	}
+	expected := "    1.1 @2 - high child +backend a=1 b=2 3d 2d today"
+	if out.String() != expected {
+		t.Errorf("expected %q, got %q", expected, out.String())
+	}
+
+	if _, err = parseTaskFormat("{{.Text"); err == nil {
+		t.Error("expected error for invalid template")
+	}
+}
+
+func TestFormatAge(t *testing.T) {
+	day := 24 * time.Hour
+	for d, expected := range map[time.Duration]string{
+		5 * time.Hour: "5h",
+		3 * day:       "3d",
+		20 * day:      "2w",
+		90 * day:      "3m",
+		800 * day:     "2y",
+	} {
+		if age := formatAge(d); age != expected {
+			t.Errorf("%s: expected %s, got %s", d, expected, age)
+		}
+	}
+}
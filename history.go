@@ -0,0 +1,188 @@
+/*
+  Copyright 2011 Alec Thomas
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+*/
+
+// A bounded journal of changes to a task list, supporting undo and redo.
+//
+// Each entry records the command that made a change, when it was made, a
+// summary of what changed, and a snapshot of the task list on the other side
+// of the change: undo entries hold the state before the change and redo
+// entries the state after it.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// Maximum number of undo entries retained.
+const maxHistory = 50
+
+type historyEntry struct {
+	Time    int64                `json:"time"`
+	Command string               `json:"command"`
+	Summary string               `json:"summary"`
+	Tasks   *marshalableTaskList `json:"tasks"`
+}
+
+type history struct {
+	Undos []*historyEntry `json:"undo"`
+	Redos []*historyEntry `json:"redo,omitempty"`
+}
+
+func historyPath(path string) string {
+	return path + ".history"
+}
+
+func loadHistory(path string) (*history, error) {
+	h := &history{}
+	data, err := os.ReadFile(historyPath(path))
+	if os.IsNotExist(err) {
+		return h, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err = json.Unmarshal(data, h); err != nil {
+		return nil, fmt.Errorf("invalid history file %s: %s", historyPath(path), err)
+	}
+	return h, nil
+}
+
+func saveHistory(path string, h *history) error {
+	data, err := json.MarshalIndent(h, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomically(historyPath(path), "", data)
+}
+
+// Record a change from before to after, made by command. Returns false, and
+// records nothing, if the task list is unchanged.
+func (h *history) Record(command string, before, after *marshalableTaskList) bool {
+	summary := summariseChanges(before, after)
+	if summary == "" {
+		return false
+	}
+	h.Undos = append(h.Undos, &historyEntry{
+		Time:    time.Now().Unix(),
+		Command: command,
+		Summary: summary,
+		Tasks:   before,
+	})
+	if len(h.Undos) > maxHistory {
+		h.Undos = h.Undos[len(h.Undos)-maxHistory:]
+	}
+	h.Redos = nil
+	return true
+}
+
+// Undo up to steps changes to current, returning the task list to restore and
+// the entries undone. Returns nil if there is nothing to undo.
+func (h *history) Undo(current *marshalableTaskList, steps int) (*marshalableTaskList, []*historyEntry) {
+	var undone []*historyEntry
+	h.Undos, h.Redos, current, undone = stepHistory(h.Undos, h.Redos, current, steps)
+	return current, undone
+}
+
+// Redo up to steps previously undone changes.
+func (h *history) Redo(current *marshalableTaskList, steps int) (*marshalableTaskList, []*historyEntry) {
+	var redone []*historyEntry
+	h.Redos, h.Undos, current, redone = stepHistory(h.Redos, h.Undos, current, steps)
+	return current, redone
+}
+
+// Pops up to steps entries from one stack, restoring their snapshots, and
+// pushes the replaced snapshots onto the other stack.
+func stepHistory(from, to []*historyEntry, current *marshalableTaskList, steps int) ([]*historyEntry, []*historyEntry, *marshalableTaskList, []*historyEntry) {
+	if len(from) == 0 {
+		return from, to, nil, nil
+	}
+	applied := []*historyEntry{}
+	for i := 0; i < steps && len(from) > 0; i++ {
+		entry := from[len(from)-1]
+		from = from[:len(from)-1]
+		to = append(to, &historyEntry{
+			Time:    entry.Time,
+			Command: entry.Command,
+			Summary: entry.Summary,
+			Tasks:   current,
+		})
+		current = entry.Tasks
+		applied = append(applied, entry)
+	}
+	return from, to, current, applied
+}
+
+// A task without its children, as compared when summarising changes.
+type flatTask struct {
+	parent int
+	data   string
+}
+
+func flattenMarshalableTasks(out map[int]flatTask, parent int, tasks []*marshalableTask) {
+	for _, task := range tasks {
+		flat := *task
+		flat.Tasks = nil
+		data, _ := json.Marshal(flat)
+		out[task.ID] = flatTask{parent, string(data)}
+		flattenMarshalableTasks(out, task.ID, task.Tasks)
+	}
+}
+
+// Summarises the differences between two task lists, eg. "added 1, removed 9".
+// Tasks are matched by ID. Returns "" if there are no differences.
+func summariseChanges(before, after *marshalableTaskList) string {
+	previous := map[int]flatTask{}
+	current := map[int]flatTask{}
+	flattenMarshalableTasks(previous, 0, before.Tasks)
+	flattenMarshalableTasks(current, 0, after.Tasks)
+	added, removed, moved, modified := 0, 0, 0, 0
+	for id, task := range current {
+		old, ok := previous[id]
+		if !ok {
+			added++
+			continue
+		}
+		if old.parent != task.parent {
+			moved++
+		}
+		if old.data != task.data {
+			modified++
+		}
+	}
+	for id := range previous {
+		if _, ok := current[id]; !ok {
+			removed++
+		}
+	}
+	changes := []string{}
+	for _, change := range []struct {
+		count int
+		what  string
+	}{{added, "added"}, {removed, "removed"}, {modified, "modified"}, {moved, "moved"}} {
+		if change.count > 0 {
+			changes = append(changes, fmt.Sprintf("%s %d", change.what, change.count))
+		}
+	}
+	if before.Title != after.Title {
+		changes = append(changes, "title changed")
+	}
+	return strings.Join(changes, ", ")
+}
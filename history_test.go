@@ -0,0 +1,100 @@
+/*
+  Copyright 2011 Alec Thomas
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+*/
+
+package main
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestHistoryUndoRedo(t *testing.T) {
+	tasks := NewTaskList()
+	tasks.Create("do A", MEDIUM)
+	h := &history{}
+	before := toMarshalableTaskList(tasks)
+	tasks.Create("do B", MEDIUM)
+	tasks.Find("1").Delete()
+	after := toMarshalableTaskList(tasks)
+	if !h.Record("todo2 test", before, after) || h.Undos[0].Summary != "added 1, removed 1" {
+		t.Fatalf("unexpected history %+v", h.Undos)
+	}
+	if h.Record("todo2 noop", after, after) {
+		t.Error("unchanged task list should not be recorded")
+	}
+
+	restored, undone := h.Undo(after, 5)
+	if len(undone) != 1 || fromMarshalableTaskList(restored).Find("1").Text() != "do A" {
+		t.Fatal("undo failed")
+	}
+	if restored, _ = h.Undo(restored, 1); restored != nil {
+		t.Error("expected nothing to undo")
+	}
+	restored, _ = h.Redo(before, 1)
+	if restored == nil || fromMarshalableTaskList(restored).Find("1").Text() != "do B" {
+		t.Error("redo failed")
+	}
+}
+
+func TestHistoryRecordsAttributeChanges(t *testing.T) {
+	tasks := NewTaskList()
+	task := tasks.Create("hello", MEDIUM)
+	h := &history{}
+	before := toMarshalableTaskList(tasks)
+	record := func(command string, change func()) {
+		change()
+		after := toMarshalableTaskList(tasks)
+		if !h.Record(command, before, after) || h.Undos[len(h.Undos)-1].Summary != "modified 1" {
+			t.Fatalf("%s: change was not recorded, %+v", command, h.Undos)
+		}
+		before = after
+	}
+	record("--set-attr 1 owner=bob", func() { task.Attributes()["owner"] = "bob" })
+	record("--unset-attr 1 owner", func() { delete(task.Attributes(), "owner") })
+	record("-e 1 +tag", func() { AddTags(task, "tag") })
+
+	restored, _ := h.Undo(before, 2)
+	undone := fromMarshalableTaskList(restored).Find("1")
+	if undone.Attributes()["owner"] != "bob" || len(undone.Tags()) != 0 {
+		t.Errorf("unexpected task after undo %v %v", undone.Attributes(), undone.Tags())
+	}
+}
+
+func TestHistoryCommandsWriteToStdout(t *testing.T) {
+	tasks := NewTaskList()
+	useTestTaskFile(t, t.TempDir(), tasks)
+	before := toMarshalableTaskList(tasks)
+	tasks.Create("do A", MEDIUM)
+	h := &history{}
+	h.Record("todo2 -a do A", before, toMarshalableTaskList(tasks))
+	if err := saveHistory(*fileFlag, h); err != nil {
+		t.Fatal(err)
+	}
+	out := &bytes.Buffer{}
+	defer func(saved io.Writer) { stdout = saved }(stdout)
+	stdout = &ansiStripper{w: out}
+
+	doShowHistory()
+	doUndo(tasks, 1)
+	doRedo(NewTaskList(), 1)
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 3 || !strings.HasSuffix(lines[0], "todo2 -a do A (added 1)") ||
+		lines[1] != "undid: todo2 -a do A (added 1)" || lines[2] != "redid: todo2 -a do A (added 1)" {
+		t.Errorf("unexpected output %q", out.String())
+	}
+}
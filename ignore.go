@@ -0,0 +1,192 @@
+/*
+  Copyright 2011 Alec Thomas
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+*/
+
+// Matching of .gitignore style ignore files.
+
+package main
+
+import (
+	"bufio"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// Names of the ignore files read from each directory.
+var ignoreFiles = []string{".gitignore", ".ignore"}
+
+type ignoreRule struct {
+	base    string // Directory containing the ignore file, "/" separated.
+	pattern *regexp.Regexp
+	negate  bool
+	dirOnly bool
+}
+
+// Rules from all ignore files that apply to a directory, in order of
+// increasing precedence.
+type ignoreRules []ignoreRule
+
+// Ignored returns true if the "/" separated path is ignored. The last
+// matching rule wins, so negated rules can re-include paths.
+func (r ignoreRules) Ignored(name string, isDir bool) bool {
+	ignored := false
+	for _, rule := range r {
+		if rule.dirOnly && !isDir {
+			continue
+		}
+		relative := name
+		if rule.base != "." {
+			if !strings.HasPrefix(name, rule.base+"/") {
+				continue
+			}
+			relative = name[len(rule.base)+1:]
+		}
+		if rule.pattern.MatchString(relative) {
+			ignored = !rule.negate
+		}
+	}
+	return ignored
+}
+
+// Load returns r extended with the rules from any ignore files in dir.
+func (r ignoreRules) Load(dir string) ignoreRules {
+	rules := r
+	for _, name := range ignoreFiles {
+		file, err := os.Open(filepath.Join(dir, name))
+		if err != nil {
+			continue
+		}
+		scanner := bufio.NewScanner(file)
+		for scanner.Scan() {
+			if rule, ok := parseIgnoreRule(filepath.ToSlash(dir), scanner.Text()); ok {
+				// Copy so that sibling directories don't share appended rules.
+				rules = append(rules[:len(rules):len(rules)], rule)
+			}
+		}
+		file.Close()
+	}
+	return rules
+}
+
+// Rules from the ignore files in the ancestors of dir, which must be absolute,
+// up to the root of the repository containing it. There are none if dir is
+// not within a repository.
+func ancestorIgnoreRules(dir string) ignoreRules {
+	root, ok := repositoryRoot(dir)
+	if !ok {
+		return nil
+	}
+	ancestors := []string{}
+	for current := dir; current != root; current = filepath.Dir(current) {
+		ancestors = append(ancestors, filepath.Dir(current))
+	}
+	rules := ignoreRules{}
+	for i := len(ancestors) - 1; i >= 0; i-- {
+		rules = rules.Load(ancestors[i])
+	}
+	return rules
+}
+
+// The root of the repository containing dir, which must be absolute.
+func repositoryRoot(dir string) (string, bool) {
+	for {
+		if _, err := os.Stat(filepath.Join(dir, ".git")); err == nil {
+			return dir, true
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", false
+		}
+		dir = parent
+	}
+}
+
+func parseIgnoreRule(base, line string) (ignoreRule, bool) {
+	rule := ignoreRule{base: path.Clean(base)}
+	line = strings.TrimRight(line, " \t\r")
+	if line == "" || strings.HasPrefix(line, "#") {
+		return rule, false
+	}
+	if strings.HasPrefix(line, "!") {
+		rule.negate = true
+		line = line[1:]
+	} else if strings.HasPrefix(line, `\`) {
+		line = line[1:]
+	}
+	if strings.HasSuffix(line, "/") {
+		rule.dirOnly = true
+		line = strings.TrimRight(line, "/")
+	}
+	// Patterns containing a slash are relative to the ignore file, others
+	// match at any depth.
+	anchored := strings.Contains(line, "/")
+	line = strings.TrimPrefix(line, "/")
+	if line == "" {
+		return rule, false
+	}
+	expr := globToRegexp(line)
+	if anchored {
+		expr = "^" + expr + "$"
+	} else {
+		expr = "^(?:.*/)?" + expr + "$"
+	}
+	pattern, err := regexp.Compile(expr)
+	if err != nil {
+		return rule, false
+	}
+	rule.pattern = pattern
+	return rule, true
+}
+
+// Convert a gitignore glob into a regular expression.
+func globToRegexp(glob string) string {
+	out := &strings.Builder{}
+	for i := 0; i < len(glob); i++ {
+		c := glob[i]
+		switch {
+		case strings.HasPrefix(glob[i:], "**/"):
+			out.WriteString("(?:.*/)?")
+			i += 2
+		case strings.HasPrefix(glob[i:], "**"):
+			out.WriteString(".*")
+			i++
+		case c == '*':
+			out.WriteString("[^/]*")
+		case c == '?':
+			out.WriteString("[^/]")
+		case c == '[':
+			end := strings.IndexByte(glob[i+1:], ']')
+			if end == -1 {
+				out.WriteString(regexp.QuoteMeta(string(c)))
+				continue
+			}
+			class := glob[i+1 : i+1+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			out.WriteString("[" + class + "]")
+			i += end + 1
+		case c == '\\' && i+1 < len(glob):
+			i++
+			out.WriteString(regexp.QuoteMeta(string(glob[i])))
+		default:
+			out.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	return out.String()
+}
@@ -14,39 +14,613 @@
   limitations under the License.
 */
 
+// Imports and synchronises TODO comments in source code with tasks.
+//
+// Imported tasks record where they came from in their attributes. Importing a
+// file again updates the tasks from that file: moved comments have their
+// locations updated, edited comments their text, and tasks whose comments
+// have been removed are marked done.
+
 package main
 
 import (
-	"bufio"
-	"io"
+	"bytes"
+	"fmt"
+	"io/fs"
 	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
 	"strings"
+	"time"
+)
+
+// Attributes of imported tasks.
+const (
+	FileAttribute   = "file"
+	LineAttribute   = "line"
+	ColumnAttribute = "column"
+	MarkerAttribute = "marker"
+	OwnerAttribute  = "owner"
+	// The priority last given to the task by its comment, so that changes to
+	// it are applied without overriding priorities set by hand.
+	PriorityAttribute = "import-priority"
+	// Identifies tasks grouping imported tasks by file or directory.
+	GroupAttribute = "import-group"
 )
 
-func importFile(file string) {
-	f, e := os.Open(file)
-	if e != nil {
-		fatalf("failed to open %s: %s", file, e.Error())
+type importGrouping int
+
+// How imported tasks are grouped below the graft point.
+const (
+	GROUPNONE = importGrouping(iota)
+	GROUPFILE
+	GROUPDIRECTORY
+)
+
+var importGroupingFromString = map[string]importGrouping{
+	"none": GROUPNONE,
+	"file": GROUPFILE,
+	"dir":  GROUPDIRECTORY,
+}
+
+// Default marker words recognised by the importer, and the priority of the
+// tasks created for them.
+var defaultMarkerPriorities = map[string]Priority{
+	"XXX":   VERYHIGH,
+	"FIXME": HIGH,
+	"TODO":  MEDIUM,
+	"NOTE":  VERYLOW,
+}
+
+// Annotations overriding the priority of a marker, eg. "TODO(p1): ...".
+var annotationPriorities = map[string]Priority{
+	"p0": VERYHIGH,
+	"p1": HIGH,
+	"p2": MEDIUM,
+	"p3": LOW,
+	"p4": VERYLOW,
+}
+
+// Marker words identifying TODO items, optionally followed by an annotation
+// in parentheses that is either a priority or an owner, eg. "TODO(alice):".
+type importMarkers struct {
+	priorities map[string]Priority
+	pattern    *regexp.Regexp
+}
+
+func newImportMarkers(priorities map[string]Priority) *importMarkers {
+	words := make([]string, 0, len(priorities))
+	for word := range priorities {
+		words = append(words, regexp.QuoteMeta(word))
+	}
+	// Longest first, so that eg. "TODO" does not shadow "TODOC".
+	sort.Slice(words, func(i, j int) bool {
+		if len(words[i]) != len(words[j]) {
+			return len(words[i]) > len(words[j])
+		}
+		return words[i] < words[j]
+	})
+	return &importMarkers{
+		priorities: priorities,
+		pattern:    regexp.MustCompile(`\b(` + strings.Join(words, "|") + `)\b(?:\(([^)]*)\))?[\s:]*(.*)$`),
+	}
+}
+
+// Parse marker priorities of the form WORD=PRIORITY, overriding the defaults.
+func parseMarkerPriorities(specs []string) (map[string]Priority, error) {
+	priorities := map[string]Priority{}
+	for word, priority := range defaultMarkerPriorities {
+		priorities[word] = priority
+	}
+	for _, spec := range specs {
+		parts := strings.SplitN(spec, "=", 2)
+		if len(parts) != 2 || parts[0] == "" {
+			return nil, fmt.Errorf("expected <marker>=<priority> but got '%s'", spec)
+		}
+		if parts[1] == "none" {
+			delete(priorities, parts[0])
+			continue
+		}
+		priority, ok := priorityMapFromString[parts[1]]
+		if !ok {
+			return nil, fmt.Errorf("invalid priority '%s' for marker %s", parts[1], parts[0])
+		}
+		priorities[parts[0]] = priority
+	}
+	if len(priorities) == 0 {
+		return nil, fmt.Errorf("no import markers defined")
+	}
+	return priorities, nil
+}
+
+// A TODO item found in a source file.
+type importedItem struct {
+	Line, Column int
+	Marker, Text string
+	Owner        string
+	Priority     Priority
+}
+
+func importFile(file string, markers *importMarkers) []importedItem {
+	data, e := os.ReadFile(file)
+	if os.IsNotExist(e) {
+		// Deleted files have no items, so their tasks are completed.
+		return nil
+	} else if e != nil {
+		fatalf("failed to read %s: %s", file, e.Error())
+	}
+	if syntax := commentSyntaxForFile(file); syntax != nil {
+		return extractCommentItems(scanComments(string(data), syntax), markers)
+	}
+	// Unknown languages are scanned line by line.
+	items := []importedItem{}
+	for i, line := range strings.Split(string(data), "\n") {
+		if item, ok := markers.match(i+1, 1, strings.TrimRight(line, "\r")); ok {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+func (m *importMarkers) match(line, column int, text string) (importedItem, bool) {
+	match := m.pattern.FindStringSubmatchIndex(text)
+	if match == nil {
+		return importedItem{}, false
+	}
+	marker := text[match[2]:match[3]]
+	item := importedItem{
+		Line:     line,
+		Column:   column + match[2],
+		Marker:   marker,
+		Text:     importedText(marker, text[match[6]:match[7]]),
+		Priority: m.priorities[marker],
+	}
+	if match[4] != -1 {
+		annotation := strings.TrimSpace(text[match[4]:match[5]])
+		if priority, ok := annotationPriorities[strings.ToLower(annotation)]; ok {
+			item.Priority = priority
+		} else if priority, ok := priorityMapFromString[strings.ToLower(annotation)]; ok {
+			item.Priority = priority
+		} else {
+			item.Owner = annotation
+		}
+	}
+	return item, true
+}
+
+// Extract TODO items from comments. Comment lines immediately following an
+// item are joined onto its text, until a blank line, another item, or the end
+// of the comment.
+func extractCommentItems(comments []commentLine, markers *importMarkers) []importedItem {
+	items := []importedItem{}
+	for i := 0; i < len(comments); i++ {
+		item, ok := markers.match(comments[i].Line, comments[i].Column, comments[i].Text)
+		if !ok {
+			continue
+		}
+		for previous := comments[i]; i+1 < len(comments) && continuesComment(previous, comments[i+1], markers); i++ {
+			previous = comments[i+1]
+			item.Text += " " + continuationText(previous.Text)
+		}
+		items = append(items, item)
+	}
+	return items
+}
+
+func continuesComment(previous, next commentLine, markers *importMarkers) bool {
+	if next.Line != previous.Line+1 || continuationText(next.Text) == "" {
+		return false
+	}
+	if _, ok := markers.match(next.Line, next.Column, next.Text); ok {
+		return false
+	}
+	if next.ID == previous.ID {
+		return true
+	}
+	// Consecutive line comments on their own lines, aligned with each other.
+	return next.Delimiter != 0 && next.Leading && previous.Leading && next.Delimiter == previous.Delimiter
+}
+
+// Strip decoration such as the leading "*" of block comment lines.
+func continuationText(text string) string {
+	text = strings.TrimSpace(text)
+	text = strings.TrimSpace(strings.TrimPrefix(text, "*"))
+	return text
+}
+
+func importedText(marker, text string) string {
+	text = strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(text), "*/"))
+	if text == "" {
+		return marker
+	}
+	return text
+}
+
+// Synchronise tasks imported from file with the items now found in it. New
+// tasks, and tasks whose marker or annotated priority has changed, are given
+// the item's priority.
+func syncImportedItems(tasks TaskList, graft TaskNode, file string, items []importedItem) {
+	existing := tasks.FindAll(func(task Task) bool {
+		return task.Attributes()[FileAttribute] == file
+	})
+	matched := make([]Task, len(items))
+	claim := func(i int, match func(task Task) bool) {
+		for j, task := range existing {
+			if task != nil && match(task) {
+				matched[i] = task
+				existing[j] = nil
+				return
+			}
+		}
+	}
+	// Match unchanged comments first, wherever they have moved to, then
+	// edited comments that are still on the same line, then edited comments
+	// that have also moved but are still similar.
+	for i, item := range items {
+		claim(i, func(task Task) bool { return task.Text() == item.Text })
+	}
+	for i, item := range items {
+		if matched[i] == nil {
+			claim(i, func(task Task) bool {
+				return task.Attributes()[LineAttribute] == strconv.Itoa(item.Line)
+			})
+		}
+	}
+	for i, item := range items {
+		if matched[i] == nil {
+			claim(i, func(task Task) bool {
+				return task.Attributes()[MarkerAttribute] == item.Marker && similarText(task.Text(), item.Text)
+			})
+		}
+	}
+	for i, item := range items {
+		task := matched[i]
+		if task == nil {
+			task = graft.Create(item.Text, item.Priority)
+		} else if importedPriorityChanged(task, item) {
+			task.SetPriority(item.Priority)
+		}
+		task.SetCompletionTime(time.Time{})
+		setImportedAttributes(task, file, item)
+	}
+	// Anything left over no longer has a comment in the source.
+	for _, task := range existing {
+		if task != nil && task.CompletionTime().IsZero() {
+			task.SetCompleted()
+		}
+	}
+}
+
+// Whether the priority given by an item's comment differs from the one last
+// imported. Tasks imported before priorities were recorded only compare the
+// marker.
+func importedPriorityChanged(task Task, item importedItem) bool {
+	if task.Attributes()[MarkerAttribute] != item.Marker {
+		return true
+	}
+	previous, ok := task.Attributes()[PriorityAttribute]
+	return ok && previous != item.Priority.String()
+}
+
+// Update the text and source location of task from item.
+func setImportedAttributes(task Task, file string, item importedItem) {
+	task.SetText(item.Text)
+	task.Attributes()[FileAttribute] = file
+	task.Attributes()[LineAttribute] = strconv.Itoa(item.Line)
+	task.Attributes()[ColumnAttribute] = strconv.Itoa(item.Column)
+	task.Attributes()[MarkerAttribute] = item.Marker
+	task.Attributes()[PriorityAttribute] = item.Priority.String()
+	if item.Owner != "" {
+		task.Attributes()[OwnerAttribute] = item.Owner
+	} else {
+		delete(task.Attributes(), OwnerAttribute)
+	}
+}
+
+// TaskLocation returns the source location recorded for task, if any. The
+// line and column are zero if unknown.
+func TaskLocation(task Task) (file string, line, column int, ok bool) {
+	file = task.Attributes()[FileAttribute]
+	if file == "" {
+		return "", 0, 0, false
+	}
+	line, _ = strconv.Atoi(task.Attributes()[LineAttribute])
+	column, _ = strconv.Atoi(task.Attributes()[ColumnAttribute])
+	return file, line, column, true
+}
+
+// LocationPath returns the path of a file recorded by the importer, which is
+// relative to the directory of the task list, as a path usable from the
+// working directory.
+func LocationPath(file string) string {
+	if filepath.IsAbs(file) {
+		return file
+	}
+	return filepath.Join(filepath.Dir(*fileFlag), file)
+}
+
+// FormatLocation formats a source location as "file:line:col".
+func FormatLocation(file string, line, column int) string {
+	if line < 1 {
+		line = 1
+	}
+	if column < 1 {
+		column = 1
+	}
+	return fmt.Sprintf("%s:%d:%d", file, line, column)
+}
+
+// Texts are similar if at least half of their distinct words are shared.
+func similarText(a, b string) bool {
+	words := map[string]int{}
+	for _, word := range strings.Fields(strings.ToLower(a)) {
+		words[word] |= 1
+	}
+	for _, word := range strings.Fields(strings.ToLower(b)) {
+		words[word] |= 2
+	}
+	shared := 0
+	for _, seen := range words {
+		if seen == 3 {
+			shared++
+		}
+	}
+	return len(words) > 0 && shared*2 >= len(words)
+}
+
+// Directories that are never imported from when walking a tree.
+var skippedDirectories = map[string]bool{
+	".git":         true,
+	".hg":          true,
+	".svn":         true,
+	".bzr":         true,
+	"vendor":       true,
+	"node_modules": true,
+}
+
+type importOptions struct {
+	Markers *importMarkers
+	Group   importGrouping
+	// Priority of all imported tasks, or -1 to derive it from their markers.
+	Priority Priority
+	// Filters applied to files found when walking directories.
+	Include []string
+	Exclude []string
+	// Files that are never imported, such as the task list itself.
+	Skip func(path string) bool
+	// Directory that imported file paths are recorded relative to, so that
+	// they do not depend on the working directory at import time.
+	Base string
+}
+
+// The path recorded in tasks imported from file: relative to the base
+// directory if possible, otherwise absolute.
+func (o *importOptions) recordedPath(file string) string {
+	path, err := filepath.Abs(file)
+	if err != nil {
+		return filepath.Clean(file)
+	}
+	base, err := filepath.Abs(o.Base)
+	if err != nil {
+		return path
+	}
+	if rel, err := filepath.Rel(base, path); err == nil {
+		return rel
+	}
+	return path
+}
+
+// Override the priority of items if a fixed priority was given.
+func (o *importOptions) prioritise(items []importedItem) {
+	if o.Priority == -1 {
+		return
+	}
+	for i := range items {
+		items[i].Priority = o.Priority
+	}
+}
+
+func matchesAnyGlob(file string, globs []string) bool {
+	for _, glob := range globs {
+		if ok, _ := filepath.Match(glob, filepath.Base(file)); ok {
+			return true
+		}
+		if ok, _ := filepath.Match(glob, file); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// Files containing a NUL byte in their first few KB are treated as binary.
+func isBinaryFile(file string) bool {
+	f, err := os.Open(file)
+	if err != nil {
+		return false
 	}
 	defer f.Close()
-	reader := bufio.NewReader(f)
-	for {
-		line, _, e := reader.ReadLine()
-		if e == io.EOF {
-			break
+	buffer := make([]byte, 8000)
+	n, _ := f.Read(buffer)
+	return bytes.IndexByte(buffer[:n], 0) != -1
+}
+
+// Recursively find importable files below root, honouring ignore files in
+// root, below it, and above it up to the root of its repository.
+func walkImportTree(root string, filter *importOptions) []string {
+	files := []string{}
+	absRoot, err := filepath.Abs(root)
+	if err != nil {
+		fatalf("failed to read %s: %s", root, err)
+	}
+	inherited := ancestorIgnoreRules(absRoot)
+	rules := map[string]ignoreRules{}
+	err = filepath.WalkDir(root, func(file string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		// Ignore rules are matched against absolute paths, as they may come
+		// from above root.
+		rel, err := filepath.Rel(root, file)
+		if err != nil {
+			return err
+		}
+		abs := filepath.Join(absRoot, rel)
+		name := filepath.ToSlash(abs)
+		if entry.IsDir() {
+			parent := rules[filepath.Dir(file)]
+			if file == root {
+				parent = inherited
+			} else if skippedDirectories[entry.Name()] || parent.Ignored(name, true) || matchesAnyGlob(file, filter.Exclude) {
+				return filepath.SkipDir
+			}
+			rules[file] = parent.Load(abs)
+			return nil
+		}
+		if !entry.Type().IsRegular() || rules[filepath.Dir(file)].Ignored(name, false) ||
+			matchesAnyGlob(file, filter.Exclude) || (filter.Skip != nil && filter.Skip(file)) {
+			return nil
 		}
-		if e != nil {
-			fatalf("error reading %s: %s", file, e.Error())
+		if len(filter.Include) > 0 && !matchesAnyGlob(file, filter.Include) {
+			return nil
 		}
-		text := string(line)
-		if strings.Contains(text, "TODO") {
-			println(text)
+		if !isBinaryFile(file) {
+			files = append(files, file)
 		}
+		return nil
+	})
+	if err != nil {
+		fatalf("failed to read %s: %s", root, err)
 	}
+	return files
 }
 
-func doImport(tasks TaskList, files []string) {
-	for _, file := range files {
-		importFile(file)
+// Import files, and directory trees given as either a directory or in the
+// form "dir/...".
+func doImport(tasks TaskList, graft TaskNode, args []string, options *importOptions) {
+	sync := func(file string) string {
+		items := importFile(file, options.Markers)
+		options.prioritise(items)
+		recorded := options.recordedPath(file)
+		parent := graft
+		if len(items) > 0 {
+			parent = importGroup(tasks, graft, recorded, options.Group)
+		}
+		syncImportedItems(tasks, parent, recorded, items)
+		return recorded
+	}
+	for _, arg := range args {
+		root := strings.TrimSuffix(arg, "...")
+		if info, err := os.Stat(root); root == arg && (err != nil || !info.IsDir()) {
+			sync(filepath.Clean(arg))
+			continue
+		}
+		root = filepath.Clean(root)
+		seen := map[string]bool{}
+		for _, file := range walkImportTree(root, options) {
+			seen[sync(file)] = true
+		}
+		// Complete tasks from files below root that were deleted or are now
+		// excluded.
+		for _, file := range importedFilesBelow(tasks, root, options.Base) {
+			if !seen[file] {
+				syncImportedItems(tasks, graft, file, nil)
+			}
+		}
+	}
+	updateImportGroups(tasks)
+	saveTaskList(tasks)
+}
+
+// Find or create the task that new tasks imported from file are added to.
+// Group tasks are found by their attributes, so remain stable if they are
+// edited or moved.
+func importGroup(tasks TaskList, graft TaskNode, file string, grouping importGrouping) TaskNode {
+	findOrCreate := func(parent TaskNode, key, text string) TaskNode {
+		matches := tasks.FindAll(func(task Task) bool { return task.Attributes()[GroupAttribute] == key })
+		if len(matches) > 0 {
+			return matches[0]
+		}
+		task := parent.Create(text, MEDIUM)
+		task.Attributes()[GroupAttribute] = key
+		return task
+	}
+	switch grouping {
+	case GROUPFILE:
+		return findOrCreate(graft, "file:"+filepath.ToSlash(file), filepath.ToSlash(file))
+	case GROUPDIRECTORY:
+		dir := filepath.ToSlash(filepath.Dir(file)) + "/"
+		parent := findOrCreate(graft, "dir:"+dir, dir)
+		return findOrCreate(parent, "file:"+filepath.ToSlash(file), filepath.Base(file))
+	}
+	return graft
+}
+
+// Whether task is a group created by importGroup. Other tasks, including
+// those the user has made to organise imported tasks, are left alone.
+func isImportGroup(task Task) bool {
+	key := task.Attributes()[GroupAttribute]
+	return strings.HasPrefix(key, "file:") || strings.HasPrefix(key, "dir:")
+}
+
+// Remove empty import groups, and give the others the highest priority of
+// their incomplete children, completing them if all of their children are.
+func updateImportGroups(tasks TaskList) {
+	groups := tasks.FindAll(isImportGroup)
+	// Deepest groups first, so that directories see the state of their files.
+	for i := len(groups) - 1; i >= 0; i-- {
+		group := groups[i]
+		if group.Len() == 0 {
+			group.Delete()
+			continue
+		}
+		priority := Priority(-1)
+		for j := 0; j < group.Len(); j++ {
+			child := group.At(j)
+			if child.CompletionTime().IsZero() && (priority == -1 || child.Priority() < priority) {
+				priority = child.Priority()
+			}
+		}
+		if priority == -1 {
+			if group.CompletionTime().IsZero() {
+				group.SetCompleted()
+			}
+		} else {
+			group.SetPriority(priority)
+			group.SetCompletionTime(time.Time{})
+		}
+	}
+}
+
+// Files that tasks were imported from below root. Relative file paths are
+// resolved against base.
+func importedFilesBelow(tasks TaskList, root, base string) []string {
+	files := []string{}
+	root, err := filepath.Abs(root)
+	if err != nil {
+		return files
+	}
+	base, err = filepath.Abs(base)
+	if err != nil {
+		return files
+	}
+	seen := map[string]bool{}
+	for _, task := range tasks.FindAll(func(task Task) bool { return task.Attributes()[FileAttribute] != "" }) {
+		file := task.Attributes()[FileAttribute]
+		if seen[file] {
+			continue
+		}
+		path := file
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(base, path)
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil || filepath.IsAbs(rel) || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			continue
+		}
+		files = append(files, file)
+		seen[file] = true
 	}
+	return files
 }
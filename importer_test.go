@@ -0,0 +1,450 @@
+/*
+  Copyright 2011 Alec Thomas
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+*/
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Write files below dir, creating directories as needed.
+func writeTestFiles(t *testing.T, dir string, files map[string]string) {
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// Use a task list file in dir for the duration of a test.
+func useTestTaskFile(t *testing.T, dir string, tasks TaskList) {
+	file, legacyFile, loaded, digest := *fileFlag, *legacyFileFlag, loadedTasks, loadedDigest
+	t.Cleanup(func() {
+		*fileFlag, *legacyFileFlag, loadedTasks, loadedDigest = file, legacyFile, loaded, digest
+	})
+	*fileFlag = filepath.Join(dir, "todo")
+	*legacyFileFlag = filepath.Join(dir, ".todo")
+	loadedTasks = toMarshalableTaskList(tasks)
+	loadedDigest = ""
+}
+
+func TestSyncImportedItems(t *testing.T) {
+	tasks := NewTaskList()
+	syncImportedItems(tasks, tasks, "a.go", []importedItem{
+		{Line: 2, Column: 4, Marker: "TODO", Text: "fix this"},
+		{Line: 4, Column: 4, Marker: "FIXME", Text: "handle errors"},
+	})
+	syncImportedItems(tasks, tasks, "a.go", []importedItem{
+		{Line: 3, Column: 4, Marker: "TODO", Text: "fix this please"},
+		{Line: 5, Column: 4, Marker: "XXX", Text: "new"},
+	})
+	if tasks.Len() != 3 {
+		t.Fatalf("expected 3 tasks, got %d", tasks.Len())
+	}
+	fix, handle, added := tasks.At(0), tasks.At(1), tasks.At(2)
+	if fix.Text() != "fix this please" || fix.Attributes()[LineAttribute] != "3" || !fix.CompletionTime().IsZero() {
+		t.Error("edited comment was not updated")
+	}
+	if handle.CompletionTime().IsZero() {
+		t.Error("removed comment was not marked done")
+	}
+	if added.Text() != "new" || added.Attributes()[FileAttribute] != "a.go" {
+		t.Error("new comment was not added")
+	}
+}
+
+func TestSyncImportedPriorities(t *testing.T) {
+	tasks := NewTaskList()
+	syncImportedItems(tasks, tasks, "a.go", []importedItem{
+		{Line: 1, Marker: "TODO", Text: "annotated", Priority: HIGH},
+		{Line: 2, Marker: "TODO", Text: "edited by hand", Priority: MEDIUM},
+	})
+	annotated, edited := tasks.At(0), tasks.At(1)
+	edited.SetPriority(VERYHIGH)
+	// The annotation of the first comment changes from p1 to p3.
+	syncImportedItems(tasks, tasks, "a.go", []importedItem{
+		{Line: 1, Marker: "TODO", Text: "annotated", Priority: LOW},
+		{Line: 2, Marker: "TODO", Text: "edited by hand", Priority: MEDIUM},
+	})
+	if annotated.Priority() != LOW {
+		t.Errorf("changed annotation was not applied, got %s", annotated.Priority())
+	}
+	if edited.Priority() != VERYHIGH {
+		t.Errorf("priority set by hand was overridden, got %s", edited.Priority())
+	}
+}
+
+func TestTaskLocation(t *testing.T) {
+	task := NewTaskList().Create("task", MEDIUM)
+	if _, _, _, ok := TaskLocation(task); ok {
+		t.Error("task without a file should have no location")
+	}
+	task.Attributes()[FileAttribute] = "src/a.go"
+	task.Attributes()[LineAttribute] = "12"
+	if file, line, column, ok := TaskLocation(task); !ok || file != "src/a.go" || line != 12 || column != 0 {
+		t.Errorf("unexpected location %s:%d:%d", file, line, column)
+	}
+	task.Attributes()[ColumnAttribute] = "5"
+	if _, _, column, _ := TaskLocation(task); column != 5 {
+		t.Errorf("unexpected column %d", column)
+	}
+}
+
+func TestFormatLocation(t *testing.T) {
+	expected := map[string]string{
+		FormatLocation("a.go", 3, 7):  "a.go:3:7",
+		FormatLocation("a.go", 0, 0):  "a.go:1:1",
+		FormatLocation("a.go", -2, 4): "a.go:1:4",
+		FormatLocation("a.go", 9, -1): "a.go:9:1",
+	}
+	for actual, want := range expected {
+		if actual != want {
+			t.Errorf("expected %s, got %s", want, actual)
+		}
+	}
+}
+
+func TestWalkImportTree(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFiles(t, dir, map[string]string{
+		"a.go":           "// TODO a\n",
+		"todo.go":        "// TODO not the task list\n",
+		"todo":           "{}",
+		"todo~":          "{}",
+		"todo.history":   "{}",
+		".todo":          "",
+		".gitignore":     "gen/\n*.log\n",
+		"gen/b.go":       "// TODO generated\n",
+		"build.log":      "TODO log\n",
+		"vendor/c.go":    "// TODO vendored\n",
+		".hidden/d.go":   "// TODO hidden\n",
+		"sub/e.py":       "# TODO e\n",
+		"sub/image.png":  "\x00\x01TODO",
+		"sub/f_test.go":  "// TODO excluded\n",
+		"sub/.ignore":    "g.go\n",
+		"sub/g.go":       "// TODO ignored\n",
+		"sub/deep/h.txt": "TODO h\n",
+	})
+	tasks := NewTaskList()
+	useTestTaskFile(t, dir, tasks)
+	options := &importOptions{Exclude: []string{"*_test.go"}, Skip: isTaskListFile}
+	files := walkImportTree(dir, options)
+	for i, file := range files {
+		rel, _ := filepath.Rel(dir, file)
+		files[i] = filepath.ToSlash(rel)
+	}
+	expected := []string{".gitignore", ".hidden/d.go", "a.go", "sub/.ignore", "sub/deep/h.txt", "sub/e.py", "todo.go"}
+	if !reflect.DeepEqual(files, expected) {
+		t.Errorf("expected %q, got %q", expected, files)
+	}
+
+	options.Include = []string{"*.py"}
+	if files := walkImportTree(dir, options); len(files) != 1 || filepath.Base(files[0]) != "e.py" {
+		t.Errorf("expected only e.py to be included, got %q", files)
+	}
+}
+
+func TestWalkImportTreeInRepository(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFiles(t, dir, map[string]string{
+		".git/HEAD":             "ref: refs/heads/main\n",
+		".gitignore":            "*.log\ngen/\n",
+		"src/a.go":              "// TODO a\n",
+		"src/debug.log":         "TODO log\n",
+		"src/gen/b.go":          "// TODO generated\n",
+		"src/.github/ci.yml":    "# TODO ci\n",
+		"src/lib/.git/config":   "TODO not source\n",
+		"src/vendor/c.go":       "// TODO vendored\n",
+		"src/node_modules/d.js": "// TODO module\n",
+	})
+	files := walkImportTree(filepath.Join(dir, "src"), &importOptions{})
+	for i, file := range files {
+		rel, _ := filepath.Rel(dir, file)
+		files[i] = filepath.ToSlash(rel)
+	}
+	expected := []string{"src/.github/ci.yml", "src/a.go"}
+	if !reflect.DeepEqual(files, expected) {
+		t.Errorf("expected %q, got %q", expected, files)
+	}
+}
+
+func TestDoImportDiffFromSubdirectory(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFiles(t, dir, map[string]string{
+		".git/HEAD": "ref: refs/heads/main\n",
+		"patch.diff": `diff --git a/src/a.go b/src/a.go
+--- a/src/a.go
++++ b/src/a.go
+@@ -1,1 +1,2 @@
+ package a
++// TODO added
+`,
+	})
+	if err := os.MkdirAll(filepath.Join(dir, "src"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	cwd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(cwd)
+	if err := os.Chdir(filepath.Join(dir, "src")); err != nil {
+		t.Fatal(err)
+	}
+	tasks := NewTaskList()
+	useTestTaskFile(t, dir, tasks)
+	options := &importOptions{Markers: newImportMarkers(defaultMarkerPriorities), Priority: -1, Base: dir}
+	doImportDiff(tasks, tasks, []string{filepath.Join(dir, "patch.diff")}, options)
+	if tasks.Len() != 1 || tasks.At(0).Attributes()[FileAttribute] != filepath.Join("src", "a.go") {
+		t.Errorf("expected a task for src/a.go, got %v", tasks.At(0).Attributes())
+	}
+}
+
+func TestDoImportResync(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFiles(t, dir, map[string]string{
+		"a.go":     "package a\n\n// TODO first\n// FIXME second\n",
+		"src/b.go": "// XXX third\n",
+		"c.go":     "// TODO named\n",
+	})
+	tasks := NewTaskList()
+	outside := tasks.Create("outside", MEDIUM)
+	outside.Attributes()[FileAttribute] = filepath.Join(filepath.Dir(dir), "elsewhere.go")
+	useTestTaskFile(t, dir, tasks)
+	options := &importOptions{Markers: newImportMarkers(defaultMarkerPriorities), Priority: -1, Skip: isTaskListFile, Base: dir}
+	doImport(tasks, tasks, []string{dir + "/..."}, options)
+	if tasks.Len() != 5 {
+		t.Fatalf("expected 5 tasks, got %d", tasks.Len())
+	}
+	texts := map[string]Task{}
+	for i := 0; i < tasks.Len(); i++ {
+		texts[tasks.At(i).Text()] = tasks.At(i)
+	}
+	if texts["second"] == nil || texts["second"].Priority() != HIGH || texts["third"] == nil {
+		t.Fatalf("unexpected imported tasks %v", texts)
+	}
+	if file := texts["third"].Attributes()[FileAttribute]; file != filepath.Join("src", "b.go") {
+		t.Errorf("expected path relative to the task list, got %s", file)
+	}
+
+	// Move one comment, remove another, delete a file and re-import.
+	writeTestFiles(t, dir, map[string]string{"a.go": "package a\n\n\n// TODO first\n"})
+	if err := os.Remove(filepath.Join(dir, "src", "b.go")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(filepath.Join(dir, "c.go")); err != nil {
+		t.Fatal(err)
+	}
+	doImport(tasks, tasks, []string{dir + "/..."}, options)
+	if tasks.Len() != 5 {
+		t.Fatalf("expected 5 tasks after re-import, got %d", tasks.Len())
+	}
+	if first := texts["first"]; !first.CompletionTime().IsZero() || first.Attributes()[LineAttribute] != "4" {
+		t.Error("moved comment was not updated")
+	}
+	if texts["second"].CompletionTime().IsZero() || texts["third"].CompletionTime().IsZero() {
+		t.Error("tasks for removed comments were not completed")
+	}
+	if !outside.CompletionTime().IsZero() {
+		t.Error("task imported from outside the tree was completed")
+	}
+
+	// Explicitly importing a deleted file completes its tasks.
+	texts["named"].SetCompletionTime(time.Time{})
+	doImport(tasks, tasks, []string{filepath.Join(dir, "c.go")}, options)
+	if texts["named"].CompletionTime().IsZero() {
+		t.Error("task for deleted file was not completed")
+	}
+}
+
+func TestImportedFilesBelow(t *testing.T) {
+	tasks := NewTaskList()
+	for _, file := range []string{"a.go", "src/b.go", "src/b.go", "../c.go", "/abs/d.go", "srcx/e.go"} {
+		task := tasks.Create(file, MEDIUM)
+		task.Attributes()[FileAttribute] = filepath.FromSlash(file)
+	}
+	expected := map[string][]string{
+		".":   {"a.go", filepath.FromSlash("src/b.go"), filepath.FromSlash("srcx/e.go")},
+		"src": {filepath.FromSlash("src/b.go")},
+	}
+	for root, files := range expected {
+		if actual := importedFilesBelow(tasks, root, "."); !reflect.DeepEqual(actual, files) {
+			t.Errorf("%s: expected %q, got %q", root, files, actual)
+		}
+	}
+}
+
+func TestIgnoreRules(t *testing.T) {
+	rules := ignoreRules{}
+	for _, line := range []string{"# comment", "*.log", "!keep.log", "build/", "/gen", "docs/**/*.md"} {
+		if rule, ok := parseIgnoreRule(".", line); ok {
+			rules = append(rules, rule)
+		}
+	}
+	expected := map[string]bool{
+		"a.log":          true,
+		"sub/b.log":      true,
+		"keep.log":       false,
+		"sub/build":      true,
+		"gen":            true,
+		"sub/gen":        false,
+		"docs/a/b/c.md":  true,
+		"docs/README.md": true,
+		"src/main.go":    false,
+	}
+	for name, ignored := range expected {
+		if rules.Ignored(name, name == "sub/build" || name == "gen" || name == "sub/gen") != ignored {
+			t.Errorf("%s: expected ignored=%v", name, ignored)
+		}
+	}
+}
+
+func TestExtractCommentItems(t *testing.T) {
+	source := `package main
+
+var TODOList = "TODO: not a comment" // TODO(alice): trailing
+// TODO: first line
+// continues here
+//
+// unrelated
+/*
+ * FIXME handle
+ * errors
+ */
+x := ` + "`// TODO raw string`" + `
+`
+	markers := newImportMarkers(defaultMarkerPriorities)
+	items := extractCommentItems(scanComments(source, commentSyntaxForFile("main.go")), markers)
+	expected := []importedItem{
+		{Line: 3, Column: 41, Marker: "TODO", Text: "trailing", Owner: "alice", Priority: MEDIUM},
+		{Line: 4, Column: 4, Marker: "TODO", Text: "first line continues here", Priority: MEDIUM},
+		{Line: 9, Column: 4, Marker: "FIXME", Text: "handle errors", Priority: HIGH},
+	}
+	if len(items) != len(expected) {
+		t.Fatalf("expected %v, got %v", expected, items)
+	}
+	for i := range expected {
+		if items[i] != expected[i] {
+			t.Errorf("expected %+v, got %+v", expected[i], items[i])
+		}
+	}
+
+	items = extractCommentItems(scanComments("s = '# TODO no'\necho $# # XXX yes\n", commentSyntaxForFile("run.sh")), markers)
+	if len(items) != 1 || items[0].Text != "yes" {
+		t.Errorf("unexpected shell items %+v", items)
+	}
+}
+
+func TestCharLiteralComments(t *testing.T) {
+	markers := newImportMarkers(defaultMarkerPriorities)
+	for file, source := range map[string]string{
+		"main.rs": "fn f<'a>(s: &'a str) -> bool { s == \"x\" || c == '\"' } // TODO quote\n" +
+			"let q = '\\''; let e = b'\"'; // FIXME escaped\n" +
+			"let s = \"// TODO in a string\";\n",
+		"main.c": "if (c == '\"') return; // TODO quote\n" +
+			"char q = '\\''; // FIXME escaped\n" +
+			"char *s = \"// TODO in a string\";\n",
+	} {
+		items := extractCommentItems(scanComments(source, commentSyntaxForFile(file)), markers)
+		if len(items) != 2 || items[0].Text != "quote" || items[1].Text != "escaped" || items[1].Line != 2 {
+			t.Errorf("%s: unexpected items %+v", file, items)
+		}
+	}
+}
+
+func TestImportMarkerPriorities(t *testing.T) {
+	priorities, err := parseMarkerPriorities([]string{"HACK=low", "NOTE=none"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	markers := newImportMarkers(priorities)
+	expected := map[string]Priority{
+		"XXX: a":        VERYHIGH,
+		"FIXME: b":      HIGH,
+		"TODO(p3): c":   LOW,
+		"TODO(high): d": HIGH,
+		"HACK e":        LOW,
+	}
+	for text, priority := range expected {
+		if item, ok := markers.match(1, 1, text); !ok || item.Priority != priority || item.Owner != "" {
+			t.Errorf("%s: expected %s, got %+v", text, priority, item)
+		}
+	}
+	if _, ok := markers.match(1, 1, "NOTE: e"); ok {
+		t.Error("disabled marker should not match")
+	}
+}
+
+func TestImportGroups(t *testing.T) {
+	tasks := NewTaskList()
+	for _, file := range []string{"src/a.go", "src/b.go", "src/a.go"} {
+		group := importGroup(tasks, tasks, file, GROUPDIRECTORY)
+		syncImportedItems(tasks, group, file, []importedItem{{Line: 1, Marker: "TODO", Text: "fix " + file, Priority: HIGH}})
+	}
+	syncImportedItems(tasks, tasks, "src/b.go", nil)
+	manual := tasks.Create("by hand", MEDIUM)
+	manual.Attributes()[GroupAttribute] = "mine"
+	empty := importGroup(tasks, tasks, "gone.go", GROUPFILE)
+	updateImportGroups(tasks)
+	if empty.Parent() != nil || manual.Parent() == nil {
+		t.Error("only empty import groups should be removed")
+	}
+	manual.Delete()
+	dir := tasks.Find("1")
+	if tasks.Len() != 1 || dir.Text() != "src/" || dir.Len() != 2 || dir.Priority() != HIGH {
+		t.Fatal("unexpected directory group")
+	}
+	if dir.At(0).Text() != "a.go" || dir.At(0).Len() != 1 || dir.At(1).CompletionTime().IsZero() {
+		t.Error("unexpected file groups")
+	}
+}
+
+func TestExtractDiffItems(t *testing.T) {
+	diff := `diff --git a/a.go b/a.go
+--- a/a.go
++++ b/a.go
+@@ -1,3 +1,4 @@
+ package x
+-// TODO removed
++// TODO added
++var s = "TODO in a string"
+ // TODO context
+@@ -10,0 +12,2 @@
++// FIXME later
++++ counter
+`
+	hunks, err := parseUnifiedDiff(strings.NewReader(diff))
+	if err != nil {
+		t.Fatal(err)
+	}
+	markers := newImportMarkers(defaultMarkerPriorities)
+	items := []importedItem{}
+	for _, hunk := range hunks {
+		items = append(items, extractHunkItems(hunk, markers)...)
+	}
+	if len(items) != 2 || items[0].Text != "added" || items[0].Line != 2 || items[1].Text != "later" || items[1].Line != 12 {
+		t.Errorf("unexpected items %+v", items)
+	}
+}
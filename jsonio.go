@@ -53,22 +53,29 @@ func (j *jsonIO) Serialize(writer io.Writer, tasks TaskList) (err error) {
 // Utility functions and structures for marshaling
 
 type marshalableTask struct {
+	ID         int                `json:"id,omitempty"`
 	Text       string             `json:"text"`
+	Note       string             `json:"note,omitempty"`
 	Priority   string             `json:"priority"`
 	Creation   int64              `json:"creation"`
 	Completion int64              `json:"completion,omitempty"`
+	Due        int64              `json:"due,omitempty"`
+	Tags       []string           `json:"tags,omitempty"`
+	Attributes map[string]string  `json:"attributes,omitempty"`
 	Tasks      []*marshalableTask `json:"tasks,omitempty"`
 }
 
 type marshalableTaskList struct {
-	Title string             `json:"title"`
-	Tasks []*marshalableTask `json:"tasks"`
+	Title  string             `json:"title"`
+	NextID int                `json:"next_id,omitempty"`
+	Tasks  []*marshalableTask `json:"tasks"`
 }
 
 func toMarshalableTaskList(t TaskList) *marshalableTaskList {
 	return &marshalableTaskList{
-		Title: t.Title(),
-		Tasks: toMarshalableTask(t),
+		Title:  t.Title(),
+		NextID: t.NextID(),
+		Tasks:  toMarshalableTask(t),
 	}
 }
 
@@ -76,18 +83,34 @@ func toMarshalableTask(n TaskNode) []*marshalableTask {
 	children := make([]*marshalableTask, n.Len())
 	for i := 0; i < n.Len(); i++ {
 		t := n.At(i)
-		var created, completed int64 = 0, 0
+		var created, completed, due int64 = 0, 0, 0
 		if !t.CreationTime().IsZero() {
 			created = t.CreationTime().Unix()
 		}
 		if !t.CompletionTime().IsZero() {
 			completed = t.CompletionTime().Unix()
 		}
+		if !t.DueTime().IsZero() {
+			due = t.DueTime().Unix()
+		}
+		// Copied, as snapshots of the task list must not change with it.
+		var attributes map[string]string
+		if len(t.Attributes()) > 0 {
+			attributes = make(map[string]string, len(t.Attributes()))
+			for key, value := range t.Attributes() {
+				attributes[key] = value
+			}
+		}
 		children[i] = &marshalableTask{
+			ID:         t.ID(),
 			Text:       t.Text(),
+			Note:       t.Note(),
 			Priority:   t.Priority().String(),
 			Creation:   created,
 			Completion: completed,
+			Due:        due,
+			Tags:       append([]string(nil), t.Tags()...),
+			Attributes: attributes,
 			Tasks:      toMarshalableTask(t),
 		}
 	}
@@ -98,16 +121,57 @@ func fromMarshalableTaskList(l *marshalableTaskList) TaskList {
 	tasks := NewTaskList()
 	tasks.SetTitle(l.Title)
 	fromMarshalableTask(tasks, l.Tasks)
+	nextID := maxMarshalableID(l.Tasks) + 1
+	if l.NextID > nextID {
+		nextID = l.NextID
+	}
+	tasks.SetNextID(nextID)
+	// Tasks without a stored ID (eg. from older files), or with the ID of an
+	// earlier task (eg. from a hand-edited file), are allocated one.
+	used := map[int]bool{}
+	unassigned := tasks.FindAll(func(task Task) bool {
+		if task.ID() == 0 || used[task.ID()] {
+			return true
+		}
+		used[task.ID()] = true
+		return false
+	})
+	for _, task := range unassigned {
+		task.SetID(tasks.NextID())
+		tasks.SetNextID(tasks.NextID() + 1)
+	}
 	return tasks
 }
 
+func maxMarshalableID(t []*marshalableTask) int {
+	max := 0
+	for _, j := range t {
+		if j.ID > max {
+			max = j.ID
+		}
+		if id := maxMarshalableID(j.Tasks); id > max {
+			max = id
+		}
+	}
+	return max
+}
+
 func fromMarshalableTask(node TaskNode, t []*marshalableTask) {
 	for _, j := range t {
 		task := node.Create(j.Text, PriorityFromString(j.Priority))
+		task.SetID(j.ID)
+		task.SetNote(j.Note)
 		task.SetCreationTime(time.Unix(j.Creation, 0).UTC())
 		if j.Completion != 0 {
 			task.SetCompletionTime(time.Unix(j.Completion, 0).UTC())
 		}
+		if j.Due != 0 {
+			task.SetDueTime(time.Unix(j.Due, 0).UTC())
+		}
+		task.SetTags(j.Tags)
+		for key, value := range j.Attributes {
+			task.Attributes()[key] = value
+		}
 		fromMarshalableTask(task, j.Tasks)
 	}
 }
@@ -0,0 +1,189 @@
+/*
+  Copyright 2011 Alec Thomas
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+*/
+
+// Machine-readable output of task queries.
+//
+// This is a stable interface for other tools, distinct from the on-disk
+// format in jsonio.go. With --output=json the task list is written as a
+// single object:
+//
+//   {"version": 1, "title": "...", "tasks": [<task>, ...]}
+//
+// and with --output=ndjson as one <task> object per line. Tasks are listed
+// in display order, parents before their children, and each is an object:
+//
+//   index       dotted index path, eg. "1.2"
+//   id          stable ID
+//   parent_id   ID of the parent task, or null for top-level tasks
+//   depth       0 for top-level tasks
+//   text, note  strings, note is "" if not set
+//   priority    veryhigh, high, medium, low or verylow
+//   done        boolean
+//   created     RFC 3339 timestamp
+//   completed   RFC 3339 timestamp, or null if not done
+//   due         RFC 3339 timestamp, or null if not due
+//   tags        array of strings, including their + or @ sigil
+//   attributes  object of string values
+//
+// Locations add "file", "line", "column" and "marker" fields to the task.
+
+package main
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Version of the JSON output schema.
+const jsonOutputVersion = 1
+
+type jsonOutputTask struct {
+	Index      string            `json:"index"`
+	ID         int               `json:"id"`
+	ParentID   *int              `json:"parent_id"`
+	Depth      int               `json:"depth"`
+	Text       string            `json:"text"`
+	Note       string            `json:"note"`
+	Priority   string            `json:"priority"`
+	Done       bool              `json:"done"`
+	Created    time.Time         `json:"created"`
+	Completed  *time.Time        `json:"completed"`
+	Due        *time.Time        `json:"due"`
+	Tags       []string          `json:"tags"`
+	Attributes map[string]string `json:"attributes"`
+}
+
+type jsonOutputLocation struct {
+	*jsonOutputTask
+	File   string `json:"file"`
+	Line   int    `json:"line"`
+	Column int    `json:"column"`
+	Marker string `json:"marker"`
+}
+
+type jsonOutputTaskList struct {
+	Version int         `json:"version"`
+	Title   string      `json:"title"`
+	Tasks   interface{} `json:"tasks"`
+}
+
+func optionalTime(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	t = t.UTC()
+	return &t
+}
+
+func toJSONOutputTask(task Task) *jsonOutputTask {
+	index := IndexOf(task)
+	out := &jsonOutputTask{
+		Index:      index.String(),
+		ID:         task.ID(),
+		Depth:      len(index) - 1,
+		Text:       task.Text(),
+		Note:       task.Note(),
+		Priority:   task.Priority().String(),
+		Done:       !task.CompletionTime().IsZero(),
+		Created:    task.CreationTime().UTC(),
+		Completed:  optionalTime(task.CompletionTime()),
+		Due:        optionalTime(task.DueTime()),
+		Tags:       append([]string{}, task.Tags()...),
+		Attributes: task.Attributes(),
+	}
+	if parent, ok := task.Parent().(Task); ok {
+		id := parent.ID()
+		out.ParentID = &id
+	}
+	return out
+}
+
+// JSONView writes tasks as JSON, or as newline delimited JSON.
+type JSONView struct {
+	NDJSON bool
+}
+
+func NewJSONView(ndjson bool) *JSONView {
+	return &JSONView{NDJSON: ndjson}
+}
+
+func (j *JSONView) write(value interface{}) {
+	encoder := json.NewEncoder(stdout)
+	if !j.NDJSON {
+		encoder.SetIndent("", "  ")
+	}
+	if err := encoder.Encode(value); err != nil {
+		fatalf("%s", err)
+	}
+}
+
+// Write a list of values, either as the tasks of a task list or one per line.
+func (j *JSONView) writeList(title string, values []interface{}) {
+	if !j.NDJSON {
+		j.write(&jsonOutputTaskList{Version: jsonOutputVersion, Title: title, Tasks: values})
+		return
+	}
+	for _, value := range values {
+		j.write(value)
+	}
+}
+
+func (j *JSONView) ShowTree(tasks TaskList, options *ViewOptions) {
+	values := []interface{}{}
+	var walk func(node TaskNode)
+	walk = func(node TaskNode) {
+		view := CreateTaskView(node, options)
+		for i := 0; i < view.Len(); i++ {
+			values = append(values, toJSONOutputTask(view.At(i)))
+			if !options.Summarise {
+				walk(view.At(i))
+			}
+		}
+	}
+	walk(tasks)
+	j.writeList(tasks.Title(), values)
+}
+
+func (j *JSONView) ShowTaskInfo(task Task) {
+	j.write(toJSONOutputTask(task))
+}
+
+func (j *JSONView) ShowAttributes(task Task) {
+	j.write(task.Attributes())
+}
+
+func (j *JSONView) ShowLocations(tasks []Task) {
+	values := []interface{}{}
+	for _, task := range tasks {
+		file, line, column, ok := TaskLocation(task)
+		if !ok {
+			continue
+		}
+		file = LocationPath(file)
+		values = append(values, &jsonOutputLocation{
+			jsonOutputTask: toJSONOutputTask(task),
+			File:           file,
+			Line:           line,
+			Column:         column,
+			Marker:         task.Attributes()[MarkerAttribute],
+		})
+	}
+	title := ""
+	if len(tasks) > 0 {
+		title = rootNode(tasks[0]).(TaskList).Title()
+	}
+	j.writeList(title, values)
+}
@@ -0,0 +1,68 @@
+/*
+  Copyright 2011 Alec Thomas
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+*/
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestJSONView(t *testing.T) {
+	defer func(saved io.Writer) { stdout = saved }(stdout)
+	tasks := NewTaskList()
+	tasks.SetTitle("Project")
+	parent := tasks.Create("parent", HIGH)
+	child := parent.Create("child", LOW)
+	child.SetCompleted()
+	child.SetTags([]string{"+x"})
+	tasks.Create("done", MEDIUM).SetCompleted()
+
+	out := &bytes.Buffer{}
+	stdout = out
+	NewJSONView(false).ShowTree(tasks, &ViewOptions{Order: INDEX, Filter: NotDone})
+	list := struct {
+		Version int
+		Title   string
+		Tasks   []map[string]interface{}
+	}{}
+	if err := json.Unmarshal(out.Bytes(), &list); err != nil {
+		t.Fatal(err)
+	}
+	if list.Version != 1 || list.Title != "Project" || len(list.Tasks) != 1 {
+		t.Fatalf("unexpected output %s", out)
+	}
+	task := list.Tasks[0]
+	if task["index"] != "1" || task["parent_id"] != nil || task["completed"] != nil || task["priority"] != "high" {
+		t.Errorf("unexpected task %v", task)
+	}
+
+	out.Reset()
+	NewJSONView(true).ShowTree(tasks, &ViewOptions{Order: INDEX})
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("expected a line per task, got %q", out)
+	}
+	if err := json.Unmarshal([]byte(lines[1]), &task); err != nil {
+		t.Fatal(err)
+	}
+	if task["index"] != "1.1" || task["parent_id"] != float64(parent.ID()) || task["done"] != true || task["completed"] == nil {
+		t.Errorf("unexpected task %v", task)
+	}
+}
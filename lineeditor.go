@@ -0,0 +1,319 @@
+/*
+  Copyright 2011 Alec Thomas
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+*/
+
+// Readline-style editing of a single line of text.
+
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Maximum number of lines kept in the line editor history file.
+const maxLineHistory = 100
+
+var errEditCancelled = errors.New("edit cancelled")
+
+type lineEditorState int
+
+// Line editor states.
+const (
+	LINEEDITING = lineEditorState(iota)
+	LINEACCEPTED
+	LINECANCELLED
+)
+
+type lineEditor struct {
+	text []rune
+	pos  int
+	// Previously entered lines, oldest first.
+	history []string
+	// Index into history while browsing it, or len(history) when editing.
+	historyPos int
+	// Text being edited before browsing history.
+	edited string
+	// Optional, completes text when tab is pressed.
+	complete func(text string) string
+}
+
+func newLineEditor(text string, history []string) *lineEditor {
+	return &lineEditor{
+		text:       []rune(text),
+		pos:        utf8.RuneCountInString(text),
+		history:    history,
+		historyPos: len(history),
+	}
+}
+
+func (e *lineEditor) String() string {
+	return string(e.text)
+}
+
+func (e *lineEditor) setText(text string) {
+	e.text = []rune(text)
+	e.pos = len(e.text)
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// Position of the start of the word before the cursor.
+func (e *lineEditor) wordStart() int {
+	pos := e.pos
+	for pos > 0 && !isWordRune(e.text[pos-1]) {
+		pos--
+	}
+	for pos > 0 && isWordRune(e.text[pos-1]) {
+		pos--
+	}
+	return pos
+}
+
+// Position of the end of the word after the cursor.
+func (e *lineEditor) wordEnd() int {
+	pos := e.pos
+	for pos < len(e.text) && !isWordRune(e.text[pos]) {
+		pos++
+	}
+	for pos < len(e.text) && isWordRune(e.text[pos]) {
+		pos++
+	}
+	return pos
+}
+
+func (e *lineEditor) deleteRange(start, end int) {
+	e.text = append(e.text[:start], e.text[end:]...)
+	e.pos = start
+}
+
+func (e *lineEditor) browseHistory(delta int) {
+	pos := e.historyPos + delta
+	if pos < 0 || pos > len(e.history) {
+		return
+	}
+	if e.historyPos == len(e.history) {
+		e.edited = e.String()
+	}
+	e.historyPos = pos
+	if pos == len(e.history) {
+		e.setText(e.edited)
+	} else {
+		e.setText(e.history[pos])
+	}
+}
+
+// HandleKey applies a key, as named by parseKeys, to the line.
+func (e *lineEditor) HandleKey(key string) lineEditorState {
+	switch key {
+	case "enter":
+		return LINEACCEPTED
+	case "esc", "ctrl-c":
+		return LINECANCELLED
+	case "left", "ctrl-b":
+		if e.pos > 0 {
+			e.pos--
+		}
+	case "right", "ctrl-f":
+		if e.pos < len(e.text) {
+			e.pos++
+		}
+	case "ctrl-left", "alt-b":
+		e.pos = e.wordStart()
+	case "ctrl-right", "alt-f":
+		e.pos = e.wordEnd()
+	case "home", "ctrl-a":
+		e.pos = 0
+	case "end", "ctrl-e":
+		e.pos = len(e.text)
+	case "backspace", "ctrl-h":
+		if e.pos > 0 {
+			e.deleteRange(e.pos-1, e.pos)
+		}
+	case "delete", "ctrl-d":
+		if e.pos < len(e.text) {
+			e.deleteRange(e.pos, e.pos+1)
+		}
+	case "ctrl-w", "alt-backspace":
+		e.deleteRange(e.wordStart(), e.pos)
+	case "alt-d":
+		e.deleteRange(e.pos, e.wordEnd())
+	case "ctrl-u":
+		e.deleteRange(0, e.pos)
+	case "ctrl-k":
+		e.text = e.text[:e.pos]
+	case "up", "ctrl-p":
+		e.browseHistory(-1)
+	case "down", "ctrl-n":
+		e.browseHistory(1)
+	case "tab":
+		if e.complete != nil {
+			e.setText(e.complete(e.String()))
+		}
+	default:
+		if r, size := utf8.DecodeRuneInString(key); size == len(key) && unicode.IsPrint(r) {
+			e.text = append(e.text[:e.pos], append([]rune{r}, e.text[e.pos:]...)...)
+			e.pos++
+		}
+	}
+	return LINEEDITING
+}
+
+// Complete a prefix of a priority name.
+func completePriority(text string) string {
+	for _, priority := range []Priority{VERYHIGH, HIGH, MEDIUM, LOW, VERYLOW} {
+		if strings.HasPrefix(priority.String(), strings.ToLower(text)) {
+			return priority.String()
+		}
+	}
+	return text
+}
+
+// Interactively edit a line on the terminal, scrolling it horizontally if it
+// is wider than the terminal.
+func readLine(prompt string, editor *lineEditor) (string, error) {
+	restore, err := makeRaw(os.Stdin.Fd())
+	if err != nil {
+		return "", fmt.Errorf("failed to configure terminal: %s", err)
+	}
+	restoreTerminal = func() {
+		restoreTerminal = nil
+		restore()
+	}
+	defer func() {
+		if restoreTerminal != nil {
+			restoreTerminal()
+		}
+	}()
+
+	buffer := make([]byte, 256)
+	for {
+		width, _ := terminalSize(os.Stdout.Fd())
+		if width <= 0 {
+			width = 80
+		}
+		available := width - utf8.RuneCountInString(prompt) - 1
+		start := 0
+		if editor.pos > available {
+			start = editor.pos - available
+		}
+		end := min(len(editor.text), start+available)
+		fmt.Printf("\r%s%s\x1b[K\r", prompt, string(editor.text[start:end]))
+		if column := utf8.RuneCountInString(prompt) + editor.pos - start; column > 0 {
+			fmt.Printf("\x1b[%dC", column)
+		}
+
+		n, err := os.Stdin.Read(buffer)
+		if err != nil {
+			return "", err
+		}
+		for _, key := range parseKeys(string(buffer[:n])) {
+			switch editor.HandleKey(key) {
+			case LINEACCEPTED:
+				fmt.Print("\r\n")
+				return editor.String(), nil
+			case LINECANCELLED:
+				fmt.Print("\r\n")
+				return "", errEditCancelled
+			}
+		}
+	}
+}
+
+func lineHistoryPath() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".todo2_history")
+}
+
+// Load the line editor history, ignoring any errors.
+func loadLineHistory(path string) []string {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	return strings.Split(strings.TrimRight(string(data), "\n"), "\n")
+}
+
+func saveLineHistory(path string, history []string, line string) error {
+	if line == "" || (len(history) > 0 && history[len(history)-1] == line) {
+		return nil
+	}
+	history = append(history, line)
+	if len(history) > maxLineHistory {
+		history = history[len(history)-maxLineHistory:]
+	}
+	return os.WriteFile(path, []byte(strings.Join(history, "\n")+"\n"), 0600)
+}
+
+// Interactively edit the text and priority of a task. If stdin is not a
+// terminal the new text is read from its first line instead.
+func doInteractiveEdit(tasks TaskList, task Task) {
+	current := strings.Join(append([]string{task.Text()}, task.Tags()...), " ")
+	if !isTerminal(os.Stdin.Fd()) {
+		line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if line = strings.TrimSpace(line); line == "" {
+			if err != nil && err != io.EOF {
+				fatalf("expected text for task %s: %s", IndexOf(task), err)
+			}
+			fatalf("expected text for task %s", IndexOf(task))
+		}
+		task.SetTags(nil)
+		doEditTask(tasks, task, -1, nil, nil, nil, line)
+		return
+	}
+
+	historyPath := lineHistoryPath()
+	history := loadLineHistory(historyPath)
+	text, err := readLine("Text: ", newLineEditor(current, history))
+	if err != nil {
+		fatalf("%s", err)
+	}
+	if text = strings.TrimSpace(text); text == "" {
+		fatalf("task text can not be empty")
+	}
+	var priority Priority
+	for {
+		editor := newLineEditor(task.Priority().String(), nil)
+		editor.complete = completePriority
+		answer, err := readLine("Priority: ", editor)
+		if err != nil {
+			fatalf("%s", err)
+		}
+		var ok bool
+		if priority, ok = priorityMapFromString[strings.TrimSpace(answer)]; ok {
+			break
+		}
+		fmt.Printf("invalid priority '%s', expected one of veryhigh, high, medium, low or verylow\n", answer)
+	}
+	if historyPath != "" {
+		if err = saveLineHistory(historyPath, history, text); err != nil {
+			fmt.Fprintf(os.Stderr, "warning: failed to save history: %s\n", err)
+		}
+	}
+	task.SetTags(nil)
+	doEditTask(tasks, task, priority, nil, nil, nil, text)
+}
@@ -0,0 +1,69 @@
+/*
+  Copyright 2011 Alec Thomas
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+*/
+
+package main
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestLineEditor(t *testing.T) {
+	e := newLineEditor("fix the parser", []string{"older", "newer"})
+	for _, key := range []string{"ctrl-w", "l", "e", "x", "e", "r", "home", "alt-d", "ctrl-right", "X", "left", "backspace"} {
+		if e.HandleKey(key) != LINEEDITING {
+			t.Fatalf("%s should not finish editing", key)
+		}
+	}
+	if e.String() != " thX lexer" || e.pos != 3 {
+		t.Errorf("unexpected line %q at %d", e.String(), e.pos)
+	}
+	e.HandleKey("up")
+	e.HandleKey("up")
+	e.HandleKey("up")
+	if e.String() != "older" {
+		t.Errorf("expected oldest history entry, got %q", e.String())
+	}
+	e.HandleKey("down")
+	e.HandleKey("down")
+	if e.String() != " thX lexer" || e.HandleKey("enter") != LINEACCEPTED {
+		t.Errorf("expected edited line to be restored, got %q", e.String())
+	}
+
+	e = newLineEditor("", nil)
+	e.complete = completePriority
+	e.HandleKey("v")
+	e.HandleKey("e")
+	e.HandleKey("tab")
+	if e.String() != "veryhigh" || e.HandleKey("esc") != LINECANCELLED {
+		t.Errorf("expected completed priority, got %q", e.String())
+	}
+}
+
+func TestLineHistory(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history")
+	history := loadLineHistory(path)
+	for _, line := range []string{"one", "two", "two", ""} {
+		if err := saveLineHistory(path, history, line); err != nil {
+			t.Fatal(err)
+		}
+		history = loadLineHistory(path)
+	}
+	if !reflect.DeepEqual(history, []string{"one", "two"}) {
+		t.Errorf("unexpected history %v", history)
+	}
+}
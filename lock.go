@@ -0,0 +1,123 @@
+/*
+  Copyright 2011 Alec Thomas
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+*/
+
+// Advisory locking and atomic replacement of task list files.
+
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"syscall"
+	"time"
+)
+
+// How often to retry while waiting for a lock.
+const lockPollInterval = 50 * time.Millisecond
+
+// Releases the lock on the task list, if held. The lock file must stay
+// referenced while the lock is needed, or its finalizer closes it and drops
+// the lock.
+var unlockTaskList func()
+
+// Acquire an exclusive advisory lock for path, waiting up to timeout. The
+// lock is held on a separate "<path>.lock" file as path itself is replaced
+// when saved. The lock is released by calling the returned function, or when
+// the process exits.
+func lockFile(path string, timeout time.Duration) (func(), error) {
+	lockPath := path + ".lock"
+	file, err := os.OpenFile(lockPath, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+	deadline := time.Now().Add(timeout)
+	for {
+		err = syscall.Flock(int(file.Fd()), syscall.LOCK_EX|syscall.LOCK_NB)
+		if err == nil {
+			break
+		}
+		if err != syscall.EWOULDBLOCK || time.Now().After(deadline) {
+			file.Close()
+			if err == syscall.EWOULDBLOCK {
+				return nil, fmt.Errorf("timed out after %s waiting for lock on %s", timeout, lockPath)
+			}
+			return nil, fmt.Errorf("failed to lock %s: %w", lockPath, err)
+		}
+		time.Sleep(lockPollInterval)
+	}
+	return func() {
+		syscall.Flock(int(file.Fd()), syscall.LOCK_UN)
+		file.Close()
+	}, nil
+}
+
+// Digest of a file's content, or "" if it does not exist.
+func fileDigest(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return dataDigest(data), nil
+}
+
+func dataDigest(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// Atomically replace path with data, via a uniquely named temporary file in
+// the same directory. If backup is not empty the previous content of path is
+// kept there.
+func writeFileAtomically(path, backup string, data []byte) error {
+	temp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	_, err = temp.Write(data)
+	if err == nil {
+		err = temp.Sync()
+	}
+	if closeErr := temp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Chmod(temp.Name(), 0644)
+	}
+	if err != nil {
+		os.Remove(temp.Name())
+		return err
+	}
+	if backup != "" {
+		if _, err = os.Stat(path); err == nil {
+			os.Remove(backup)
+			if err = os.Link(path, backup); err != nil {
+				os.Remove(temp.Name())
+				return fmt.Errorf("unable to back up %s to %s: %s", path, backup, err)
+			}
+		}
+	}
+	if err = os.Rename(temp.Name(), path); err != nil {
+		os.Remove(temp.Name())
+		return fmt.Errorf("unable to rename %s to %s: %s", temp.Name(), path, err)
+	}
+	return nil
+}
@@ -0,0 +1,43 @@
+/*
+  Copyright 2011 Alec Thomas
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+*/
+
+package main
+
+import (
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestLockFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "todo")
+	unlock, err := lockFile(path, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The lock must survive garbage collection while it is still held.
+	runtime.GC()
+	runtime.GC()
+	if _, err := lockFile(path, 0); err == nil {
+		t.Fatal("expected the lock to be held")
+	}
+	unlock()
+	again, err := lockFile(path, 0)
+	if err != nil {
+		t.Fatalf("expected the lock to be released, got %s", err)
+	}
+	again()
+}
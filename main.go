@@ -17,10 +17,16 @@
 package main
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
+	"io"
 	"os"
+	"path/filepath"
+	"sort"
 	"strconv"
 	"strings"
+	"syscall"
 	"time"
 
 	"github.com/alecthomas/kingpin/v2"
@@ -39,73 +45,233 @@ Items can be prioritised and are displayed in a hierarchy, so that one item may
 depend on another.
 
 
-  todo2 [-A]
-    Display (all) tasks.
-
-  todo2 [-p <priority>] -a <text>
-    Create a new task.
-
-  todo2 -d <index>
-    Mark a task as complete.
-
-  todo2 [-p <priority>] -e <task> [<text>]
-    Edit an existing task.
+  todo2 [-A] [-t <tag>] [-f <expr>]
+    Display (all) tasks, optionally only those with the given tag or matching
+    a filter expression. Ancestors of matching tasks are also displayed.
+
+  todo2 [-p <priority>] [--due <date>] -a <text>
+    Create a new task. Words in the text such as +backend or @alice are
+    removed and added to the task as tags. Due dates are dates, weekdays,
+    today, tomorrow, or an offset from now in hours, days, weeks, months or
+    years, eg. +3d or +1m (one month, not one minute).
+
+  todo2 -d <index>...|-f <expr>
+    Mark tasks as complete.
+
+  todo2 [-p <priority>] [--due <date>] -e <task> [<text>]
+    Edit an existing task. If no text or other changes are given, the text
+    and priority are edited interactively.
+
+  todo2 --tui
+    Browse and edit tasks interactively. Press ? for a summary of keys.
+
+  todo2 --edit-in-editor [<task>]
+    Edit a task and its sub-tasks, or all tasks, as indented text in $VISUAL
+    or $EDITOR. Lines can be edited, reordered, re-indented, added or deleted.
+
+  todo2 --note <task> [<text>]
+    Set the multi-line note of a task from text, redirected stdin, or by
+    editing it in $VISUAL or $EDITOR. Tasks with notes are marked with * in
+    the tree.
+
+  todo2 --undo|--redo [<n>]
+    Undo or redo the last (n) changes. --history lists the changes.
+
+  todo2 [-g <graft>] --import <file>|<dir>/...
+    Create tasks for TODO, FIXME and XXX comments in source files. Importing
+    again updates those tasks, marking them done when the comment is removed.
+    Tasks are prioritised by their marker: XXX veryhigh, FIXME high, TODO
+    medium and NOTE verylow, or by an annotation such as "TODO(p1):" (p0 to
+    p4) or "TODO(high):". Use --marker to change these or add new markers.
+    Tasks are added below the graft task, optionally grouped below a task
+    for each file or directory with --group.
+    Comments in Go, C/C++, Java, JavaScript/TypeScript, Rust, Python, shell,
+    Ruby and SQL are recognised, so that string literals are ignored and
+    multi-line comments are joined. The owner in "TODO(alice):" is recorded
+    in the task's "owner" attribute.
+    Directories are imported recursively, skipping binary files, version
+    control and vendored directories, and anything matched by .gitignore or
+    .ignore files. Use --include and --exclude to further select files.
+
+  git diff | todo2 --import-diff [<diff>]
+    Create tasks only for TODO items in lines added by a unified diff. Paths
+    in the diff are relative to the root of the git repository, if any.
+
+  todo2 --export <format> [<file>]
+  todo2 [-g <task>] --import --import-format <format> [<file>...]
+    Export the task list to a file or stdout, or import tasks from files or
+    stdin below the graft task. Formats are json, md, a Markdown checklist
+    with "(high)" style priorities and tags written inline, and todotxt, the
+    todo.txt format with the hierarchy kept in id: and parent: attributes.
+
+  todo2 --locations [-A] [-f <expr>]
+    List the source locations of imported tasks in the file:line:col: text
+    format understood by Vim (:cexpr), Emacs compilation-mode and VS Code.
+
+Filter expressions combine comparisons with and, or, not and parentheses, eg.
+
+  priority>=high and not done and text~"parser" and created<7d
+
+Fields are priority, text, tag, id, created, completed, due and attr.<key>;
+operators are = != < <= > >= ~ (regular expression) and !~. Times are dates
+or durations (h, d, w, m, y). Flags are done, overdue, due and tagged.
+
+Tasks are referenced either by their dotted index in the tree (eg. 1.2) or by
+their stable ID prefixed with @ (eg. @12). IDs are shown by --info and do not
+change when tasks are removed, purged or reparented.
+
+Tasks can be displayed in a custom format with --format, using Go template
+syntax, eg. --format '{{.Indent}}{{.Index}} [{{.Priority}}] {{.Text}} {{.Age}}'.
+Fields are Index, Number, ID, Depth, Indent, State, Done, Priority, Text,
+Note, Tags, Attributes, Created, Completed, Age, Duration, Due and DueState.
+{{colour "<element>"}} and {{reset}} change colours, eg. {{colour .Priority}}.
+
+With --output=json or --output=ndjson, tasks are written as JSON for use by
+other tools, eg. todo2 -A -o ndjson | jq .text. See the README for the schema.
+
+Colour is used when output is to a terminal, unless $NO_COLOR is set or
+--color=never is given. Redirected output is neither coloured nor wrapped.
+
+Settings are read from ~/.todorc, $XDG_CONFIG_HOME/todo2/config and .todo2rc
+in the current directory, each overriding the last, and are overridden by
+command-line flags. Each line is "<key> = <value>", eg.
+
+  order = due
+  filter = not done and priority>=medium
+  priority = low
+  date-format = %d/%m/%Y
+  theme = solarized
+  colour.title = bright #268bd2
+  alias.urgent = -f "priority>=high and not done"
+
+Keys are file, legacy-file, order, lock-timeout, group, color, format,
+summary-format (used with --summary), filter (the default
+display filter), priority (of new tasks), date-format (strftime style), theme
+(dark, light, solarized or monochrome), colour.<element> and alias.<name>.
+Elements are title, number, tag, note, done, each priority, and due, duesoon
+and overdue. Colours are attributes (bright, dim, underline, reverse), a name
+(eg. red), a 256 colour index (eg. 208) or #rrggbb, and "on-" followed by a
+background colour.
 `
 
 // Actions
 var addFlag = kingpin.Flag("add", "Add a task.").Short('a').Bool()
 var editFlag = kingpin.Flag("edit", "Edit a task, replacing its text.").Short('e').Bool()
+var tuiFlag = kingpin.Flag("tui", "Browse and edit tasks interactively.").Bool()
+var editInEditorFlag = kingpin.Flag("edit-in-editor", "Edit a task and its sub-tasks, or all tasks, in $EDITOR.").Bool()
 var markDoneFlag = kingpin.Flag("done", "Mark the given tasks as done.").Short('d').Bool()
 var markNotDoneFlag = kingpin.Flag("not-done", "Mark the given tasks as not done.").Short('D').Bool()
 var removeFlag = kingpin.Flag("remove", "Remove the given tasks.").Bool()
 var reparentFlag = kingpin.Flag("reparent", "Reparent task A below task B").Bool()
 var titleFlag = kingpin.Flag("title", "Set the task list title.").Bool()
 var infoFlag = kingpin.Flag("info", "Show information on a task.").Bool()
+var noteFlag = kingpin.Flag("note", "Set the note of a task (<task> [<text>]) from text, stdin, or $EDITOR.").Bool()
+var setAttributeFlag = kingpin.Flag("set-attr", "Set attributes on a task (<task> <key>=<value> ...).").Bool()
+var unsetAttributeFlag = kingpin.Flag("unset-attr", "Remove attributes from a task (<task> <key> ...).").Bool()
+var attributesFlag = kingpin.Flag("attrs", "Show the attributes of a task.").Bool()
+var undoFlag = kingpin.Flag("undo", "Undo the last change, or the last N changes.").Bool()
+var redoFlag = kingpin.Flag("redo", "Redo the last undone change, or the last N undone changes.").Bool()
+var historyFlag = kingpin.Flag("history", "Show the history of changes that can be undone.").Bool()
 var importFlag = kingpin.Flag("import", "Import and synchronise TODO items from source code.").Bool()
+var exportFlag = kingpin.Flag("export", "Write the task list to a file, or stdout, in this format ("+strings.Join(taskListFormatNames(), ",")+").").PlaceHolder("FORMAT").Enum(taskListFormatNames()...)
 var purgeFlag = kingpin.Flag("purge", "Purge completed tasks older than this.").Default("-1s").PlaceHolder("0s").Duration()
 
 // Options
+var importDiffFlag = kingpin.Flag("import-diff", "Import TODO items from lines added by a unified diff, read from a file or stdin.").Bool()
+var locationsFlag = kingpin.Flag("locations", "List the source locations of imported tasks as file:line:col: text.").Bool()
+var importFormatFlag = kingpin.Flag("import-format", "Import task lists in this format with --import, rather than TODO comments ("+strings.Join(taskListFormatNames(), ",")+").").PlaceHolder("FORMAT").Enum(taskListFormatNames()...)
+var markerFlag = kingpin.Flag("marker", "Import comments with this marker at the given priority, or 'none' to ignore the marker.").PlaceHolder("WORD=PRIORITY").Strings()
+var groupFlag = kingpin.Flag("group", "Group imported tasks below a task for each file, or for each directory and file (none,file,dir).").Default("none").Enum("none", "file", "dir")
+var includeFlag = kingpin.Flag("include", "Only import files matching this glob when importing directories.").PlaceHolder("GLOB").Strings()
+var excludeFlag = kingpin.Flag("exclude", "Do not import files or directories matching this glob.").PlaceHolder("GLOB").Strings()
 var priorityFlag = kingpin.Flag("priority", "priority of newly created tasks (veryhigh,high,medium,low,verylow)").Short('p').
 	PlaceHolder("medium").Enum("veryhigh", "high", "medium", "low", "verylow")
+var dueFlag = kingpin.Flag("due", "Due date of new or edited tasks (eg. today, tomorrow, fri, 2026-11-03, +3d, +1m for a month, none).").String()
+var tagFlag = kingpin.Flag("tag", "Tag new or edited tasks, or only show tasks with this tag.").Short('t').PlaceHolder("TAG").Strings()
+var untagFlag = kingpin.Flag("untag", "Remove a tag from edited tasks.").PlaceHolder("TAG").Strings()
 var graftFlag = kingpin.Flag("graft", "Task to graft new tasks to.").Short('g').Default("root").String()
 var fileFlag = kingpin.Flag("file", "Flie to load task lists from.").Default(".todo2").String()
 var legacyFileFlag = kingpin.Flag("legacy-file", "File to load legacy task lists from.").Default(".todo").String()
+var lockTimeoutFlag = kingpin.Flag("lock-timeout", "How long to wait for another todo2 to release the task list.").Default("5s").Duration()
 var allFlag = kingpin.Flag("all", "Show all tasks, even completed ones.").Short('A').Bool()
+var filterFlag = kingpin.Flag("filter", "Only show or act on tasks matching this expression (eg. 'priority>=high and not done').").Short('f').PlaceHolder("EXPR").String()
+var formatFlag = kingpin.Flag("format", "Template used to display each task, eg. '{{.Index}} {{.Text}}'.").PlaceHolder("TEMPLATE").String()
+var outputFlag = kingpin.Flag("output", "Output format of task lists, --info, --attrs and --locations (text, json, ndjson).").Short('o').Default("text").Enum("text", "json", "ndjson")
+var colorFlag = kingpin.Flag("color", "When to use colour (auto, always, never).").Default("auto").Enum("auto", "always", "never")
 var summaryFlag = kingpin.Flag("summary", "Summarise tasks to one line.").Short('s').Bool()
-var orderFlag = kingpin.Flag("order", "Specify display order of tasks ([-]index,created,completed,text,priority,duration,done)").Default("priority").Enum(orderEnum...)
+var orderFlag = kingpin.Flag("order", "Specify display order of tasks ([-]index,created,completed,text,priority,duration,done,due)").Default("priority").Enum(orderEnum...)
+
+// Configuration, loaded before the command-line is parsed.
+var config = newConfig()
 
 // Task text.
-var taskText = kingpin.Arg("arg", "Task text or index.").Strings()
+var taskText = kingpin.Arg("arg", "Task text, index or @id.").Strings()
 
 var orderEnum = []string{
-	"index", "created", "completed", "text", "priority", "duration", "done",
-	"-index", "-created", "-completed", "-text", "-priority", "-duration", "-done",
+	"index", "created", "completed", "text", "priority", "duration", "done", "due", "deadline",
+	"-index", "-created", "-completed", "-text", "-priority", "-duration", "-done", "-due", "-deadline",
 }
 
-func doView(tasks TaskList) {
+func doView(tasks TaskList, filter Predicate) {
 	order, reversed := OrderFromString(*orderFlag)
+	if !*allFlag && *filterFlag == "" && config.Filter != "" {
+		defaultFilter, err := CompileFilter(config.Filter, time.Now())
+		if err != nil {
+			fatalf("invalid default filter: %s", err)
+		}
+		filter = And(defaultFilter, filter)
+	}
+	format := config.Format
+	if *summaryFlag && config.SummaryFormat != "" {
+		format = config.SummaryFormat
+	}
+	if *formatFlag != "" {
+		format = *formatFlag
+	}
 	options := &ViewOptions{
-		ShowAll:   *allFlag,
+		Format:    format,
 		Summarise: *summaryFlag,
 		Order:     order,
 		Reversed:  reversed,
+		Filter:    filter,
 	}
-	view := NewConsoleView()
+	view := newView()
 	view.ShowTree(tasks, options)
 }
 
-func doAdd(tasks TaskList, graft TaskNode, priority Priority, text string) {
-	graft.Create(text, priority)
+// The view selected by --output.
+func newView() View {
+	switch *outputFlag {
+	case "json":
+		return NewJSONView(false)
+	case "ndjson":
+		return NewJSONView(true)
+	}
+	return NewConsoleView()
+}
+
+func doAdd(tasks TaskList, graft TaskNode, priority Priority, due time.Time, tags []string, text string) {
+	text, textTags := ParseTags(text)
+	task := graft.Create(text, priority)
+	task.SetDueTime(due)
+	AddTags(task, append(textTags, tags...)...)
 	saveTaskList(tasks)
 }
 
-func doEditTask(tasks TaskList, task Task, priority Priority, text string) {
+func doEditTask(tasks TaskList, task Task, priority Priority, due *time.Time, tags, untags []string, text string) {
 	if text != "" {
+		text, textTags := ParseTags(text)
 		task.SetText(text)
+		AddTags(task, textTags...)
 	}
+	AddTags(task, tags...)
+	RemoveTags(task, untags...)
 	if priority != -1 {
 		task.SetPriority(priority)
 	}
+	if due != nil {
+		task.SetDueTime(*due)
+	}
 	saveTaskList(tasks)
 }
 
@@ -157,12 +323,166 @@ func doShowInfo(tasks TaskList, index string) {
 	if task == nil {
 		fatalf("no such task %s", index)
 	}
-	view := NewConsoleView()
+	view := newView()
 	view.ShowTaskInfo(task)
 }
 
+// Set the note of task to text, or if text is empty to the content of stdin
+// if it is redirected, otherwise by editing the note.
+func doSetNote(tasks TaskList, task Task, text string) {
+	switch {
+	case text != "":
+	case !isTerminal(os.Stdin.Fd()):
+		note, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			fatalf("failed to read note: %s", err)
+		}
+		text = string(note)
+	default:
+		note, err := editText(task.Note(), "todo2-note-*.txt")
+		if err != nil {
+			fatalf("%s", err)
+		}
+		text = note
+	}
+	task.SetNote(strings.TrimRight(text, "\n"))
+	saveTaskList(tasks)
+}
+
+func doSetAttributes(tasks TaskList, task Task, assignments []string) {
+	for _, assignment := range assignments {
+		parts := strings.SplitN(assignment, "=", 2)
+		if len(parts) != 2 || parts[0] == "" {
+			fatalf("expected <key>=<value> but got '%s'", assignment)
+		}
+		task.Attributes()[parts[0]] = parts[1]
+	}
+	saveTaskList(tasks)
+}
+
+func doUnsetAttributes(tasks TaskList, task Task, keys []string) {
+	for _, key := range keys {
+		if _, ok := task.Attributes()[key]; !ok {
+			fatalf("no such attribute %s on task %s", key, IndexOf(task).String())
+		}
+		delete(task.Attributes(), key)
+	}
+	saveTaskList(tasks)
+}
+
+func doShowAttributes(tasks TaskList, index string) {
+	view := newView()
+	view.ShowAttributes(resolveTaskReference(tasks, index))
+}
+
+func doUndo(tasks TaskList, steps int) {
+	h, err := loadHistory(*fileFlag)
+	if err != nil {
+		fatalf("%s", err)
+	}
+	restored, undone := h.Undo(toMarshalableTaskList(tasks), steps)
+	if restored == nil {
+		fatalf("nothing to undo")
+	}
+	writeTaskList(fromMarshalableTaskList(restored))
+	if err = saveHistory(*fileFlag, h); err != nil {
+		fatalf("failed to save history: %s", err)
+	}
+	for _, entry := range undone {
+		fmt.Fprintf(stdout, "undid: %s (%s)\n", entry.Command, entry.Summary)
+	}
+}
+
+func doRedo(tasks TaskList, steps int) {
+	h, err := loadHistory(*fileFlag)
+	if err != nil {
+		fatalf("%s", err)
+	}
+	restored, redone := h.Redo(toMarshalableTaskList(tasks), steps)
+	if restored == nil {
+		fatalf("nothing to redo")
+	}
+	writeTaskList(fromMarshalableTaskList(restored))
+	if err = saveHistory(*fileFlag, h); err != nil {
+		fatalf("failed to save history: %s", err)
+	}
+	for _, entry := range redone {
+		fmt.Fprintf(stdout, "redid: %s (%s)\n", entry.Command, entry.Summary)
+	}
+}
+
+func doShowLocations(tasks TaskList, filter Predicate) {
+	if !*allFlag && *filterFlag == "" && config.Filter != "" {
+		defaultFilter, err := CompileFilter(config.Filter, time.Now())
+		if err != nil {
+			fatalf("invalid default filter: %s", err)
+		}
+		filter = And(defaultFilter, filter)
+	}
+	located := tasks.FindAll(func(task Task) bool {
+		_, _, _, ok := TaskLocation(task)
+		return ok && (filter == nil || filter(task))
+	})
+	sort.SliceStable(located, func(i, j int) bool {
+		leftFile, leftLine, _, _ := TaskLocation(located[i])
+		rightFile, rightLine, _, _ := TaskLocation(located[j])
+		if leftFile != rightFile {
+			return leftFile < rightFile
+		}
+		return leftLine < rightLine
+	})
+	view := newView()
+	view.ShowLocations(located)
+}
+
+func doShowHistory() {
+	h, err := loadHistory(*fileFlag)
+	if err != nil {
+		fatalf("%s", err)
+	}
+	for i := len(h.Undos) - 1; i >= 0; i-- {
+		entry := h.Undos[i]
+		when := time.Unix(entry.Time, 0).Local().Format(dateLayout + " 15:04")
+		fmt.Fprintf(stdout, "%2d. %s  %s (%s)\n", len(h.Undos)-i, when, entry.Command, entry.Summary)
+	}
+}
+
+func importOptionsFromFlags(priority Priority) *importOptions {
+	markers, err := parseMarkerPriorities(*markerFlag)
+	if err != nil {
+		fatalf("%s", err)
+	}
+	if *priorityFlag == "" {
+		priority = -1
+	}
+	return &importOptions{
+		Markers:  newImportMarkers(markers),
+		Group:    importGroupingFromString[*groupFlag],
+		Priority: priority,
+		Include:  *includeFlag,
+		Exclude:  *excludeFlag,
+		Skip:     isTaskListFile,
+		Base:     filepath.Dir(*fileFlag),
+	}
+}
+
+// Parse an optional step count for --undo and --redo.
+func historySteps(args []string) int {
+	if len(args) == 0 {
+		return 1
+	}
+	steps, err := strconv.Atoi(args[0])
+	if err != nil || steps < 1 {
+		fatalf("invalid number of steps '%s'", args[0])
+	}
+	return steps
+}
+
 func processAction(tasks TaskList) {
 	priority := PriorityFromString(*priorityFlag)
+	if *priorityFlag == "" {
+		priority = PriorityFromString(config.Priority)
+	}
 	var graft TaskNode = tasks // -golint
 	if *graftFlag != "root" {
 		if graft = tasks.Find(*graftFlag); graft == nil {
@@ -170,19 +490,43 @@ func processAction(tasks TaskList) {
 		}
 	}
 
+	var filter Predicate
+	if *filterFlag != "" {
+		var err error
+		if filter, err = CompileFilter(*filterFlag, time.Now()); err != nil {
+			fatalf("%s", err)
+		}
+	}
+
+	// nil if --due was not given, zero if it should be cleared.
+	var due *time.Time
+	if *dueFlag != "" {
+		due = &time.Time{}
+		if *dueFlag != "none" {
+			parsed, err := ParseDueTime(*dueFlag, time.Now())
+			if err != nil {
+				fatalf("%s", err)
+			}
+			due = &parsed
+		}
+	}
+
 	switch {
 	case *addFlag:
 		if len(*taskText) == 0 {
 			fatalf("expected text for new task")
 		}
 		text := strings.Join(*taskText, " ")
-		doAdd(tasks, graft, priority, text)
+		if due == nil {
+			due = &time.Time{}
+		}
+		doAdd(tasks, graft, priority, *due, *tagFlag, text)
 	case *markDoneFlag:
-		doMarkDone(tasks, resolveTaskReferences(tasks, *taskText))
+		doMarkDone(tasks, resolveTaskReferences(tasks, *taskText, filter))
 	case *markNotDoneFlag:
-		doMarkNotDone(tasks, resolveTaskReferences(tasks, *taskText))
+		doMarkNotDone(tasks, resolveTaskReferences(tasks, *taskText, filter))
 	case *removeFlag:
-		doRemove(tasks, resolveTaskReferences(tasks, *taskText))
+		doRemove(tasks, resolveTaskReferences(tasks, *taskText, filter))
 	case *reparentFlag:
 		if len(*taskText) < 1 {
 			fatalf("expected <task> [<new-parent>] for reparenting")
@@ -201,11 +545,53 @@ func processAction(tasks TaskList) {
 			fatalf("expected <task> for info")
 		}
 		doShowInfo(tasks, (*taskText)[0])
+	case *tuiFlag:
+		doTUI(tasks)
+	case *editInEditorFlag:
+		var task Task
+		if len(*taskText) > 0 {
+			task = resolveTaskReference(tasks, (*taskText)[0])
+		}
+		doEditInEditor(tasks, task)
+	case *noteFlag:
+		if len(*taskText) < 1 {
+			fatalf("expected <task> [<text>] for note")
+		}
+		doSetNote(tasks, resolveTaskReference(tasks, (*taskText)[0]), strings.Join((*taskText)[1:], " "))
+	case *setAttributeFlag:
+		if len(*taskText) < 2 {
+			fatalf("expected <task> <key>=<value> ...")
+		}
+		doSetAttributes(tasks, resolveTaskReference(tasks, (*taskText)[0]), (*taskText)[1:])
+	case *unsetAttributeFlag:
+		if len(*taskText) < 2 {
+			fatalf("expected <task> <key> ...")
+		}
+		doUnsetAttributes(tasks, resolveTaskReference(tasks, (*taskText)[0]), (*taskText)[1:])
+	case *attributesFlag:
+		if len(*taskText) < 1 {
+			fatalf("expected <task> for attributes")
+		}
+		doShowAttributes(tasks, (*taskText)[0])
+	case *undoFlag:
+		doUndo(tasks, historySteps(*taskText))
+	case *redoFlag:
+		doRedo(tasks, historySteps(*taskText))
+	case *historyFlag:
+		doShowHistory()
+	case *locationsFlag:
+		doShowLocations(tasks, And(WithTags(*tagFlag), filter))
+	case *importFlag && *importFormatFlag != "":
+		doImportTaskList(tasks, graft, *importFormatFlag, *taskText)
 	case *importFlag:
 		if len(*taskText) < 1 {
 			fatalf("expected list of files to import")
 		}
-		doImport(tasks, *taskText)
+		doImport(tasks, graft, *taskText, importOptionsFromFlags(priority))
+	case *exportFlag != "":
+		doExport(tasks, *exportFlag, *taskText)
+	case *importDiffFlag:
+		doImportDiff(tasks, graft, *taskText, importOptionsFromFlags(priority))
 	case *editFlag:
 		if len(*taskText) < 1 {
 			fatalf("expected [-p <priority>] <task> [<text>]")
@@ -215,17 +601,28 @@ func processAction(tasks TaskList) {
 			fatalf("invalid task %s", (*taskText)[0])
 		}
 		text := strings.Join((*taskText)[1:], " ")
+		if text == "" && *priorityFlag == "" && due == nil && len(*tagFlag) == 0 && len(*untagFlag) == 0 {
+			doInteractiveEdit(tasks, task)
+			return
+		}
 		if *priorityFlag == "" {
 			priority = -1
 		}
-		doEditTask(tasks, task, priority, text)
+		doEditTask(tasks, task, priority, due, *tagFlag, *untagFlag, text)
 	case *purgeFlag != -1*time.Second:
 		doPurge(tasks, *purgeFlag)
 	default:
-		doView(tasks)
+		doView(tasks, And(WithTags(*tagFlag), filter))
 	}
 }
 
+// Whether the command only reads the task list, so does not need to lock it.
+func isReadOnlyAction() bool {
+	return !(*addFlag || *editFlag || *tuiFlag || *editInEditorFlag || *markDoneFlag || *markNotDoneFlag ||
+		*removeFlag || *reparentFlag || *titleFlag || *noteFlag || *setAttributeFlag || *unsetAttributeFlag ||
+		*undoFlag || *redoFlag || *importFlag || *importDiffFlag || *purgeFlag != -1*time.Second)
+}
+
 func resolveTaskReference(tasks TaskList, index string) Task {
 	task := tasks.Find(index)
 	if task == nil {
@@ -267,7 +664,12 @@ func expandRange(indexRange string) []string {
 	return rangeIndexes
 }
 
-func resolveTaskReferences(tasks TaskList, indices []string) []Task {
+// Resolve task indices and ranges, or if none are given, all tasks matching
+// filter.
+func resolveTaskReferences(tasks TaskList, indices []string, filter Predicate) []Task {
+	if len(indices) == 0 && filter != nil {
+		return tasks.FindAll(filter)
+	}
 	references := make([]Task, 0, len(indices))
 	for _, index := range indices {
 		if strings.Index(index, "-") == -1 {
@@ -293,12 +695,38 @@ func resolveTaskReferences(tasks TaskList, indices []string) []Task {
 	return references
 }
 
+// Returns true for the task list file and its backup, history and lock files.
+func isTaskListFile(path string) bool {
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return false
+	}
+	for _, file := range []string{*fileFlag, *legacyFileFlag} {
+		file, err := filepath.Abs(file)
+		if err != nil {
+			continue
+		}
+		for _, suffix := range []string{"", "~", ".lock", ".history"} {
+			if path == file+suffix {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Digest of the task file as loaded, used to detect modifications by other
+// processes before saving.
+var loadedDigest string
+
 func loadTaskList() (tasks TaskList, err error) {
 	// Try loading new-style task file
-	if file, err := os.Open(*fileFlag); err == nil {
-		defer file.Close()
+	if data, err := os.ReadFile(*fileFlag); err == nil {
+		loadedDigest = dataDigest(data)
 		loader := NewJSONIO()
-		return loader.Deserialize(file)
+		return loader.Deserialize(bytes.NewReader(data))
+	} else if !os.IsNotExist(err) {
+		return nil, err
 	}
 	// Try loading legacy task file
 	if file, err := os.Open(*legacyFileFlag); err == nil {
@@ -309,40 +737,79 @@ func loadTaskList() (tasks TaskList, err error) {
 	return nil, nil
 }
 
+// Snapshot of the task list as loaded, used to record changes in the history.
+var loadedTasks *marshalableTaskList
+
+// Save tasks, recording the change in the undo history.
 func saveTaskList(tasks TaskList) {
-	path := *fileFlag
-	previous := path + "~"
-	temp := path + "~~"
-	var serializeError error
-	if file, err := os.Create(temp); err == nil {
-		defer func() {
-			if err = file.Close(); err != nil {
-				os.Remove(temp)
-			} else {
-				if serializeError != nil {
-					return
-				}
-				if _, err = os.Stat(path); err == nil {
-					if err = os.Rename(path, previous); err != nil {
-						fatalf("unable to rename %s to %s", path, previous)
-					}
-				}
-				if err = os.Rename(temp, path); err != nil {
-					fatalf("unable to rename %s to %s", temp, path)
-				}
-			}
-		}()
-		writer := NewJSONIO()
-		if serializeError = writer.Serialize(file, tasks); serializeError != nil {
-			fatalf(serializeError.Error())
+	h, err := loadHistory(*fileFlag)
+	if err != nil {
+		fatalf("%s", err)
+	}
+	command := strings.Join(append([]string{filepath.Base(os.Args[0])}, os.Args[1:]...), " ")
+	saved := toMarshalableTaskList(tasks)
+	changed := h.Record(command, loadedTasks, saved)
+	writeTaskList(tasks)
+	loadedTasks = saved
+	if changed {
+		if err = saveHistory(*fileFlag, h); err != nil {
+			fatalf("failed to save history: %s", err)
 		}
 	}
 }
 
+// Write tasks, refusing to overwrite changes made by another process since
+// the task list was loaded.
+func writeTaskList(tasks TaskList) {
+	path := *fileFlag
+	digest, err := fileDigest(path)
+	if err != nil {
+		fatalf("%s", err)
+	}
+	if digest != loadedDigest {
+		fatalf("%s was modified by another process since it was loaded, not saving", path)
+	}
+	buffer := &bytes.Buffer{}
+	writer := NewJSONIO()
+	if err = writer.Serialize(buffer, tasks); err != nil {
+		fatalf("%s", err)
+	}
+	if err = writeFileAtomically(path, path+"~", buffer.Bytes()); err != nil {
+		fatalf("%s", err)
+	}
+	loadedDigest = dataDigest(buffer.Bytes())
+}
+
 func main() {
 	kingpin.CommandLine.Help = usage
+	// "@" references tasks by ID, so must not be expanded as an arguments file.
+	kingpin.EnableFileExpansion = false
 	kingpin.Version("2.2.0").Author("Alec Thomas <alec@swapoff.org>")
-	kingpin.Parse()
+	var err error
+	if config, err = loadConfig(); err != nil {
+		fatalf("%s", err)
+	}
+	if err = config.Apply(kingpin.CommandLine); err != nil {
+		fatalf("%s", err)
+	}
+	kingpin.MustParse(kingpin.CommandLine.Parse(config.ExpandAlias(os.Args[1:])))
+	if !useColour(*colorFlag, isTerminal(os.Stdout.Fd()), os.Getenv) {
+		stdout = &ansiStripper{w: os.Stdout}
+	}
+
+	// Held for the whole load/modify/save cycle of commands that change the
+	// task list, so that viewing it does not create a lock file. A read-only
+	// directory can still be viewed, but any attempt to save will fail.
+	if !isReadOnlyAction() {
+		unlock, err := lockFile(*fileFlag, *lockTimeoutFlag)
+		if err != nil && !os.IsPermission(err) && !errors.Is(err, syscall.EROFS) {
+			fatalf("%s", err)
+		}
+		if unlock != nil {
+			unlockTaskList = unlock
+			defer unlock()
+		}
+	}
 
 	tasks, err := loadTaskList()
 	if err != nil {
@@ -351,5 +818,6 @@ func main() {
 	if tasks == nil {
 		tasks = NewTaskList()
 	}
+	loadedTasks = toMarshalableTaskList(tasks)
 	processAction(tasks)
 }
@@ -0,0 +1,182 @@
+/*
+  Copyright 2011 Alec Thomas
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+*/
+
+// Reads and writes task lists as Markdown checklists:
+//
+//   # Title
+//
+//   - [ ] (high) Fix the parser +backend
+//     - [x] Write a test
+//       A note, indented below its task.
+//       \- A note line that would otherwise be a list item.
+//
+// Priorities other than medium are written before the text, and tags after
+// it. Creation and completion times are not preserved.
+
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+)
+
+var markdownItemPattern = regexp.MustCompile(`^(\s*)[-*+]\s+(?:\[([ xX])\]\s*)?(.*)$`)
+var markdownPriorityPattern = regexp.MustCompile(`^\((veryhigh|high|medium|low|verylow)\)\s*`)
+
+// Note lines starting with a list marker, or a backslash, are escaped with a
+// backslash so that they are not read back as tasks.
+var markdownNoteEscapePattern = regexp.MustCompile(`^(\s*)([-*+\\])`)
+var markdownNoteUnescapePattern = regexp.MustCompile(`^(\s*)\\([-*+\\])`)
+
+type markdownIO struct {
+	TaskListIO
+}
+
+func NewMarkdownIO() TaskListIO {
+	return &markdownIO{}
+}
+
+// Width of leading whitespace, counting tabs as four spaces.
+func indentWidth(text string) int {
+	width := 0
+	for _, c := range text {
+		switch c {
+		case ' ':
+			width++
+		case '\t':
+			width += 4
+		default:
+			return width
+		}
+	}
+	return width
+}
+
+// Remove up to width columns of leading whitespace from line, counting tabs
+// as four spaces.
+func trimIndent(line string, width int) string {
+	column := 0
+	for i, c := range line {
+		if column >= width || (c != ' ' && c != '\t') {
+			return line[i:]
+		}
+		if c == '\t' {
+			column += 4
+		} else {
+			column++
+		}
+	}
+	return ""
+}
+
+func (m *markdownIO) Deserialize(reader io.Reader) (TaskList, error) {
+	type level struct {
+		indent int
+		node   TaskNode
+	}
+	tasks := NewTaskList()
+	stack := []level{{-1, tasks}}
+	var last Task
+	// Blank lines since the last line read.
+	blanks := 0
+	scanner := bufio.NewScanner(reader)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), " \t\r")
+		if strings.TrimSpace(line) == "" {
+			blanks++
+			continue
+		}
+		if strings.HasPrefix(line, "# ") && tasks.Title() == "" && last == nil {
+			tasks.SetTitle(strings.TrimSpace(line[2:]))
+			continue
+		}
+		if match := markdownItemPattern.FindStringSubmatch(line); match != nil {
+			indent := indentWidth(match[1])
+			for stack[len(stack)-1].indent >= indent {
+				stack = stack[:len(stack)-1]
+			}
+			priority := MEDIUM
+			text := match[3]
+			if marker := markdownPriorityPattern.FindStringSubmatch(text); marker != nil {
+				priority = PriorityFromString(marker[1])
+				text = text[len(marker[0]):]
+			}
+			text, tags := ParseTags(text)
+			last = stack[len(stack)-1].node.Create(text, priority)
+			last.SetTags(tags)
+			if match[2] == "x" || match[2] == "X" {
+				last.SetCompleted()
+			}
+			stack = append(stack, level{indent, last})
+			blanks = 0
+			continue
+		}
+		// Lines indented below a task are its note, less the indent of the
+		// task and the two spaces setting notes apart. Anything else, such as
+		// other headings or paragraphs, is ignored.
+		if indent := stack[len(stack)-1].indent; last != nil && indentWidth(line) > indent {
+			note := last.Note()
+			if note != "" {
+				note += strings.Repeat("\n", blanks+1)
+			}
+			line = trimIndent(line, indent+2)
+			last.SetNote(note + markdownNoteUnescapePattern.ReplaceAllString(line, "$1$2"))
+		}
+		blanks = 0
+	}
+	return tasks, scanner.Err()
+}
+
+func (m *markdownIO) Serialize(writer io.Writer, tasks TaskList) error {
+	out := bufio.NewWriter(writer)
+	if tasks.Title() != "" {
+		fmt.Fprintf(out, "# %s\n\n", tasks.Title())
+	}
+	var write func(depth int, task Task)
+	write = func(depth int, task Task) {
+		indent := strings.Repeat("  ", depth)
+		state := " "
+		if !task.CompletionTime().IsZero() {
+			state = "x"
+		}
+		priority := ""
+		if task.Priority() != MEDIUM {
+			priority = "(" + task.Priority().String() + ") "
+		}
+		text := strings.Join(append([]string{task.Text()}, task.Tags()...), " ")
+		fmt.Fprintf(out, "%s- [%s] %s%s\n", indent, state, priority, text)
+		if task.Note() != "" {
+			for _, line := range strings.Split(task.Note(), "\n") {
+				if line == "" {
+					fmt.Fprintln(out)
+				} else {
+					line = markdownNoteEscapePattern.ReplaceAllString(line, `$1\$2`)
+					fmt.Fprintf(out, "%s  %s\n", indent, line)
+				}
+			}
+		}
+		for i := 0; i < task.Len(); i++ {
+			write(depth+1, task.At(i))
+		}
+	}
+	for i := 0; i < tasks.Len(); i++ {
+		write(0, tasks.At(i))
+	}
+	return out.Flush()
+}
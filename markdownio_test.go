@@ -0,0 +1,82 @@
+/*
+  Copyright 2011 Alec Thomas
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+*/
+
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestMarkdownRoundTrip(t *testing.T) {
+	text := `# Project
+
+- [ ] (high) Fix the parser +backend
+  - [x] Write a test
+    First line.
+    Second line.
+
+    Second paragraph.
+    \- bullet in note
+    \\- backslash
+
+
+    Third paragraph.
+        indented code
+          \- indented bullet
+- [ ] (verylow) Tidy up
+`
+	tasks, err := NewMarkdownIO().Deserialize(strings.NewReader(text))
+	if err != nil {
+		t.Fatal(err)
+	}
+	parser := tasks.At(0)
+	if tasks.Title() != "Project" || tasks.Len() != 2 || parser.Priority() != HIGH || parser.Tags()[0] != "+backend" {
+		t.Fatal("unexpected task list")
+	}
+	if test := parser.At(0); test.CompletionTime().IsZero() || test.Note() != "First line.\nSecond line.\n\nSecond paragraph.\n- bullet in note\n\\- backslash\n\n\nThird paragraph.\n    indented code\n      - indented bullet" {
+		t.Errorf("unexpected sub-task %q", test.Note())
+	}
+	out := &bytes.Buffer{}
+	if err = NewMarkdownIO().Serialize(out, tasks); err != nil {
+		t.Fatal(err)
+	}
+	if out.String() != text {
+		t.Errorf("round trip failed, got:\n%s", out)
+	}
+}
+
+func TestMarkdownChecklists(t *testing.T) {
+	text := "Some introduction.\n\n* [X] done\n\t* not a checkbox\n* [ ] (urgent) next\n\n## Other heading\n"
+	tasks, err := NewMarkdownIO().Deserialize(strings.NewReader(text))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tasks.Len() != 2 || tasks.At(0).Len() != 1 || tasks.At(0).At(0).Text() != "not a checkbox" {
+		t.Fatal("unexpected task list")
+	}
+	if tasks.At(1).Text() != "(urgent) next" || tasks.At(1).Priority() != MEDIUM {
+		t.Error("unknown priority markers should be kept as text")
+	}
+
+	target := NewTaskList()
+	graft := target.Create("graft", MEDIUM)
+	copyTask(graft, tasks.At(0))
+	if copied := graft.At(0); copied.ID() != 2 || copied.At(0).ID() != 3 || copied.CompletionTime().IsZero() {
+		t.Error("copied tasks should get new IDs and keep their details")
+	}
+}
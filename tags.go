@@ -0,0 +1,119 @@
+/*
+  Copyright 2011 Alec Thomas
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+*/
+
+// Task tags. Tags are stored with their sigil, "+" for projects or areas
+// (eg. +backend) and "@" for people or contexts (eg. @alice).
+
+package main
+
+import (
+	"regexp"
+	"strings"
+)
+
+var tagPattern = regexp.MustCompile(`^[+@][A-Za-z][\w\-./]*$`)
+
+var wordPattern = regexp.MustCompile(`\S+`)
+
+// ParseTags extracts +tag and @tag tokens from text, returning the remaining
+// text, with its spacing otherwise unchanged, and the tags found.
+func ParseTags(text string) (string, []string) {
+	remaining := &strings.Builder{}
+	tags := []string{}
+	previous := 0
+	for _, word := range wordPattern.FindAllStringIndex(text, -1) {
+		if tagPattern.MatchString(text[word[0]:word[1]]) {
+			tags = append(tags, text[word[0]:word[1]])
+		} else {
+			if remaining.Len() > 0 {
+				remaining.WriteString(text[previous:word[0]])
+			}
+			remaining.WriteString(text[word[0]:word[1]])
+		}
+		previous = word[1]
+	}
+	return remaining.String(), tags
+}
+
+// NormaliseTag adds the default "+" sigil to a tag without one.
+func NormaliseTag(tag string) string {
+	if strings.HasPrefix(tag, "+") || strings.HasPrefix(tag, "@") {
+		return tag
+	}
+	return "+" + tag
+}
+
+// AddTags adds tags to task, ignoring any it already has.
+func AddTags(task Task, tags ...string) {
+	current := task.Tags()
+	for _, tag := range tags {
+		if tag == "" {
+			continue
+		}
+		tag = NormaliseTag(tag)
+		if !containsString(current, tag) {
+			current = append(current, tag)
+		}
+	}
+	task.SetTags(current)
+}
+
+// RemoveTags removes tags from task.
+func RemoveTags(task Task, tags ...string) {
+	remaining := []string{}
+	for _, tag := range task.Tags() {
+		if !TagMatches(tag, tags) {
+			remaining = append(remaining, tag)
+		}
+	}
+	task.SetTags(remaining)
+}
+
+// TagMatches returns true if tag matches any of patterns. A pattern without a
+// sigil matches a tag with either sigil. Empty tags and patterns never match.
+func TagMatches(tag string, patterns []string) bool {
+	if tag == "" {
+		return false
+	}
+	for _, pattern := range patterns {
+		if pattern == "" {
+			continue
+		}
+		if tag == pattern || tag[1:] == pattern {
+			return true
+		}
+	}
+	return false
+}
+
+// HasAnyTag returns true if task has a tag matching any of patterns.
+func HasAnyTag(task Task, patterns []string) bool {
+	for _, tag := range task.Tags() {
+		if TagMatches(tag, patterns) {
+			return true
+		}
+	}
+	return false
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,54 @@
+/*
+  Copyright 2011 Alec Thomas
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+*/
+
+package main
+
+import (
+	"testing"
+)
+
+func TestParseTags(t *testing.T) {
+	text, tags := ParseTags("Fix +backend login @alice for C++ @42")
+	if text != "Fix login for C++ @42" || len(tags) != 2 || tags[0] != "+backend" || tags[1] != "@alice" {
+		t.Errorf("unexpected %q %v", text, tags)
+	}
+	text, tags = ParseTags("+ui Align  columns:\tname @bob and size ")
+	if text != "Align  columns:\tname and size" || len(tags) != 2 {
+		t.Errorf("unexpected %q %v", text, tags)
+	}
+}
+
+func TestTagMatchesEmpty(t *testing.T) {
+	if TagMatches("", []string{"backend"}) || TagMatches("+backend", []string{""}) {
+		t.Error("empty tags should not match")
+	}
+	task := NewTaskList().Create("task", MEDIUM)
+	AddTags(task, "", "backend")
+	if len(task.Tags()) != 1 || !HasAnyTag(task, []string{"", "backend"}) {
+		t.Errorf("unexpected tags %v", task.Tags())
+	}
+}
+
+func TestTagFilterKeepsAncestors(t *testing.T) {
+	tasks := NewTaskList()
+	a := tasks.Create("do A", MEDIUM)
+	AddTags(a.Create("do B", MEDIUM), "backend")
+	tasks.Create("do C", MEDIUM)
+	view := CreateTaskView(tasks, &ViewOptions{Order: INDEX, Filter: WithTags([]string{"backend"})})
+	if view.Len() != 1 || view.At(0) != a {
+		t.Fail()
+	}
+}
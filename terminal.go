@@ -0,0 +1,83 @@
+/*
+  Copyright 2011 Alec Thomas
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+*/
+
+// Terminal capability detection.
+
+package main
+
+import (
+	"io"
+	"os"
+)
+
+// Width used when output is to a terminal of unknown width.
+const defaultTerminalWidth = 80
+
+// Console output, which strips colour when it is disabled.
+var stdout io.Writer = os.Stdout
+
+// Whether to use colour for the given --color mode, honouring NO_COLOR
+// (https://no-color.org) and dumb terminals in auto mode.
+func useColour(mode string, terminal bool, getenv func(string) string) bool {
+	switch mode {
+	case "always":
+		return true
+	case "never":
+		return false
+	}
+	return terminal && getenv("NO_COLOR") == "" && getenv("TERM") != "dumb"
+}
+
+// ansiStripper removes ANSI escape sequences from everything written to it.
+type ansiStripper struct {
+	w     io.Writer
+	state int
+}
+
+// ansiStripper states.
+const (
+	ANSITEXT = iota
+	ANSIESCAPE
+	ANSISEQUENCE
+)
+
+func (a *ansiStripper) Write(p []byte) (int, error) {
+	out := make([]byte, 0, len(p))
+	for _, c := range p {
+		switch a.state {
+		case ANSIESCAPE:
+			a.state = ANSITEXT
+			if c == '[' {
+				a.state = ANSISEQUENCE
+			}
+		case ANSISEQUENCE:
+			// Control sequences end with a byte in the range @ to ~.
+			if c >= 0x40 && c <= 0x7e {
+				a.state = ANSITEXT
+			}
+		default:
+			if c == 0x1b {
+				a.state = ANSIESCAPE
+			} else {
+				out = append(out, c)
+			}
+		}
+	}
+	if _, err := a.w.Write(out); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
@@ -0,0 +1,51 @@
+/*
+  Copyright 2011 Alec Thomas
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+*/
+
+package main
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestUseColour(t *testing.T) {
+	env := map[string]string{}
+	getenv := func(key string) string { return env[key] }
+	if !useColour("auto", true, getenv) || useColour("auto", false, getenv) {
+		t.Error("auto should only use colour on a terminal")
+	}
+	env["NO_COLOR"] = "1"
+	if useColour("auto", true, getenv) || !useColour("always", false, getenv) {
+		t.Error("NO_COLOR should only apply in auto mode")
+	}
+	if useColour("never", true, func(string) string { return "" }) {
+		t.Error("never should not use colour")
+	}
+}
+
+func TestANSIStripper(t *testing.T) {
+	out := &bytes.Buffer{}
+	stripper := &ansiStripper{w: out}
+	// Escape sequences may be split across writes.
+	for _, text := range []string{BRIGHT + FGRED + "do A", "\x1b[38;5", ";208mdo B" + RESET + "\n"} {
+		if n, err := stripper.Write([]byte(text)); err != nil || n != len(text) {
+			t.Fatalf("write failed: %d, %v", n, err)
+		}
+	}
+	if out.String() != "do Ado B\n" {
+		t.Errorf("unexpected output %q", out.String())
+	}
+}
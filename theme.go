@@ -0,0 +1,191 @@
+/*
+  Copyright 2011 Alec Thomas
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+*/
+
+// Colour themes and colour descriptions.
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Themes map display elements to colour descriptions. The dark theme matches
+// the built-in colours.
+var themes = map[string]map[string]string{
+	"dark": {
+		"title": "bright green", "number": "green", "tag": "magenta", "note": "bright cyan", "done": "",
+		"veryhigh": "bright red", "high": "bright yellow", "medium": "white", "low": "cyan", "verylow": "blue",
+		"due": "dim", "duesoon": "bright yellow", "overdue": "bright red reverse",
+	},
+	"light": {
+		"title": "bright blue", "number": "blue", "tag": "magenta", "note": "bright magenta", "done": "244",
+		"veryhigh": "bright red", "high": "bright 130", "medium": "black", "low": "24", "verylow": "244",
+		"due": "dim", "duesoon": "bright 130", "overdue": "bright white on-red",
+	},
+	"solarized": {
+		"title": "bright #268bd2", "number": "#859900", "tag": "#d33682", "note": "#2aa198", "done": "#586e75",
+		"veryhigh": "bright #dc322f", "high": "#cb4b16", "medium": "#839496", "low": "#6c71c4", "verylow": "#586e75",
+		"due": "#586e75", "duesoon": "#b58900", "overdue": "bright #dc322f reverse",
+	},
+	"monochrome": {
+		"title": "bright", "number": "none", "tag": "underline", "note": "bright", "done": "dim",
+		"veryhigh": "bright underline", "high": "bright", "medium": "none", "low": "none", "verylow": "dim",
+		"due": "none", "duesoon": "bright", "overdue": "reverse",
+	},
+}
+
+// Configurable display elements, other than priorities and due states.
+var colourElements = map[string]*string{
+	"title":  &TITLE_COLOUR,
+	"number": &NUMBER_COLOR,
+	"tag":    &TAG_COLOUR,
+	"note":   &NOTE_COLOUR,
+	"done":   &DONE_COLOUR,
+}
+
+var colourNames = map[string]int{
+	"black":   0,
+	"red":     1,
+	"green":   2,
+	"yellow":  3,
+	"blue":    4,
+	"magenta": 5,
+	"cyan":    6,
+	"white":   7,
+}
+
+var colourAttributes = map[string]string{
+	"none":       "",
+	"bright":     BRIGHT,
+	"bold":       BRIGHT,
+	"dim":        DIM,
+	"underscore": UNDERSCORE,
+	"underline":  UNDERSCORE,
+	"blink":      BLINK,
+	"reverse":    REVERSE,
+	"hidden":     HIDDEN,
+}
+
+// Whether the terminal supports 24-bit colour. If not, RGB colours are
+// approximated with the 256 colour palette.
+var truecolour = os.Getenv("COLORTERM") == "truecolor" || os.Getenv("COLORTERM") == "24bit"
+
+// Parse a colour description into ANSI escapes. Descriptions are made up of
+// attributes (eg. "bright"), a foreground colour and a background colour
+// prefixed with "on-". Colours are names (eg. "red"), 256 colour palette
+// indices (eg. "208") or RGB values (eg. "#ff8700").
+func parseColour(description string) (string, error) {
+	colour := ""
+	for _, word := range strings.Fields(strings.ToLower(description)) {
+		if attribute, ok := colourAttributes[word]; ok {
+			colour += attribute
+		} else if code, ok := colourCode(strings.TrimPrefix(word, "on-"), strings.HasPrefix(word, "on-")); ok {
+			colour += code
+		} else {
+			return "", fmt.Errorf("unknown colour '%s'", word)
+		}
+	}
+	return colour, nil
+}
+
+func colourCode(name string, background bool) (string, bool) {
+	base := 30
+	if background {
+		base = 40
+	}
+	if index, ok := colourNames[name]; ok {
+		return fmt.Sprintf("\x1b[%dm", base+index), true
+	}
+	if index, err := strconv.Atoi(name); err == nil && index >= 0 && index <= 255 {
+		return fmt.Sprintf("\x1b[%d;5;%dm", base+8, index), true
+	}
+	if len(name) == 7 && name[0] == '#' {
+		rgb, err := strconv.ParseUint(name[1:], 16, 32)
+		if err != nil {
+			return "", false
+		}
+		r, g, b := int(rgb>>16), int(rgb>>8&0xff), int(rgb&0xff)
+		if !truecolour {
+			return fmt.Sprintf("\x1b[%d;5;%dm", base+8, nearestPaletteColour(r, g, b)), true
+		}
+		return fmt.Sprintf("\x1b[%d;2;%d;%d;%dm", base+8, r, g, b), true
+	}
+	return "", false
+}
+
+// Index of the closest colour in the 6x6x6 colour cube of the 256 colour
+// palette.
+func nearestPaletteColour(r, g, b int) int {
+	levels := []int{0, 95, 135, 175, 215, 255}
+	nearest := func(value int) int {
+		best := 0
+		for i, level := range levels {
+			if abs(level-value) < abs(levels[best]-value) {
+				best = i
+			}
+		}
+		return best
+	}
+	return 16 + 36*nearest(r) + 6*nearest(g) + nearest(b)
+}
+
+func abs(value int) int {
+	if value < 0 {
+		return -value
+	}
+	return value
+}
+
+func isColourElement(element string) bool {
+	_, isElement := colourElements[element]
+	_, isPriority := priorityMapFromString[element]
+	_, isDueState := dueStateFromString[element]
+	return isElement || isPriority || isDueState
+}
+
+// Set the colour of a display element, a priority or a due state (duesoon,
+// overdue or due), returning false if the element is unknown.
+func setColour(element, colour string) bool {
+	if target, ok := colourElements[element]; ok {
+		*target = colour
+	} else if priority, ok := priorityMapFromString[element]; ok {
+		colourPriorityMap[priority] = colour
+	} else if state, ok := dueStateFromString[element]; ok {
+		colourDueMap[state] = colour
+	} else {
+		return false
+	}
+	return true
+}
+
+// Apply a theme, then per-element overrides of it.
+func applyColours(theme string, overrides map[string]string) error {
+	for _, colours := range []map[string]string{themes[theme], overrides} {
+		for element, description := range colours {
+			colour, err := parseColour(description)
+			if err != nil {
+				return err
+			}
+			if !setColour(element, colour) {
+				return fmt.Errorf("unknown colour element '%s'", element)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,63 @@
+/*
+  Copyright 2011 Alec Thomas
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+*/
+
+package main
+
+import (
+	"testing"
+)
+
+func TestParseColour(t *testing.T) {
+	defer func(saved bool) { truecolour = saved }(truecolour)
+	truecolour = true
+	for description, expected := range map[string]string{
+		"bright red on-white": BRIGHT + FGRED + BGWHITE,
+		"208 on-#102030":      "\x1b[38;5;208m\x1b[48;2;16;32;48m",
+		"none":                "",
+	} {
+		if colour, err := parseColour(description); err != nil || colour != expected {
+			t.Errorf("%s: expected %q, got %q (%v)", description, expected, colour, err)
+		}
+	}
+	truecolour = false
+	if colour, _ := parseColour("#ff8700"); colour != "\x1b[38;5;208m" {
+		t.Errorf("expected 256 colour approximation, got %q", colour)
+	}
+	if _, err := parseColour("256"); err == nil {
+		t.Error("expected error for out of range colour")
+	}
+}
+
+func TestThemes(t *testing.T) {
+	// The dark theme is the default, so applying it must not change anything.
+	title, overdue, high := TITLE_COLOUR, colourDueMap[OVERDUE], colourPriorityMap[HIGH]
+	if err := applyColours("dark", nil); err != nil {
+		t.Fatal(err)
+	}
+	if TITLE_COLOUR != title || colourDueMap[OVERDUE] != overdue || colourPriorityMap[HIGH] != high {
+		t.Error("dark theme does not match the built-in colours")
+	}
+	for name, theme := range themes {
+		if len(theme) != len(themes["dark"]) {
+			t.Errorf("theme %s does not define every element", name)
+		}
+		for element, description := range theme {
+			if _, err := parseColour(description); err != nil || !isColourElement(element) {
+				t.Errorf("theme %s: invalid %s = %s", name, element, description)
+			}
+		}
+	}
+}
@@ -45,6 +45,7 @@ const (
 	DURATION
 	DONE
 	INDEX
+	DUE
 )
 
 type TaskListIO interface {
@@ -53,6 +54,8 @@ type TaskListIO interface {
 }
 
 type TaskNode interface {
+	// ID is a persistent identifier, unique within a TaskList, that does not
+	// change when the tree is reordered.
 	ID() int
 	At(index int) Task
 	Len() int
@@ -69,9 +72,15 @@ type TaskNode interface {
 type Task interface {
 	TaskNode
 
+	SetID(id int)
+
 	Text() string
 	SetText(text string)
 
+	// Optional multi-line description of the task.
+	Note() string
+	SetNote(note string)
+
 	Priority() Priority
 	SetPriority(priority Priority)
 
@@ -82,6 +91,14 @@ type Task interface {
 	SetCompletionTime(time time.Time)
 	CompletionTime() time.Time
 
+	// Zero if the task has no due time.
+	SetDueTime(time time.Time)
+	DueTime() time.Time
+
+	// Tags including their sigil, eg. "+backend" or "@alice".
+	Tags() []string
+	SetTags(tags []string)
+
 	// Extra attributes usable by extensions
 	Attributes() map[string]string
 }
@@ -92,13 +109,22 @@ type TaskList interface {
 	Title() string
 	SetTitle(title string)
 
+	// Next ID that will be allocated to a newly created task.
+	NextID() int
+	SetNextID(id int)
+
+	// Find a task by its dotted index (eg. "1.2.3") or by its ID (eg. "@42").
 	Find(index string) Task
+	FindByID(id int) Task
 	FindAll(predicate func(node Task) bool) []Task
 }
 
 // Index referencing a task
 type Index []int
 
+// Prefix used to reference a task by ID rather than by Index.
+const IDPrefix = "@"
+
 // Implementation
 
 var priorityMapFromString = map[string]Priority{
@@ -144,6 +170,8 @@ var orderFromString = map[string]Order{
 	"lifetime":   DURATION,
 	"duration":   DURATION,
 	"done":       DONE,
+	"due":        DUE,
+	"deadline":   DUE,
 }
 
 var orderToString = map[Order]string{
@@ -154,6 +182,7 @@ var orderToString = map[Order]string{
 	PRIORITY:  "priority",
 	DURATION:  "duration",
 	DONE:      "done",
+	DUE:       "due",
 }
 
 func (t Order) String() string {
@@ -174,6 +203,7 @@ func OrderFromString(order string) (Order, bool) {
 
 type taskNodeImpl struct {
 	id         int
+	node       TaskNode // The Task or TaskList embedding this node.
 	tasks      []TaskNode
 	parent     TaskNode
 	attributes map[string]string
@@ -215,23 +245,26 @@ func (t *taskNodeImpl) SetParent(parent TaskNode) {
 }
 
 func (t *taskNodeImpl) Append(child TaskNode) {
-	child.SetParent(t)
+	child.SetParent(t.node)
 	t.tasks = append(t.tasks, child)
 }
 
 func (t *taskNodeImpl) Create(title string, priority Priority) Task {
-	task := newTask(t.Len(), title, priority)
+	task := newTask(0, title, priority)
 	t.Append(task)
+	if root, ok := rootNode(t.node).(*taskListImpl); ok {
+		task.SetID(root.allocateID())
+	}
 	return task
 }
 
 func (t *taskNodeImpl) Delete() {
-	parent := t.Parent().(*taskNodeImpl)
-	if parent == nil {
+	if t.parent == nil {
 		panic("can not delete root node")
 	}
-	for i := 0; i < parent.Len(); i++ {
-		if parent.At(i).Equal(t) {
+	parent := nodeImpl(t.parent)
+	for i, child := range parent.tasks {
+		if child == t.node {
 			parent.tasks = append(parent.tasks[:i], parent.tasks[i+1:]...)
 			t.parent = nil
 			return
@@ -240,27 +273,53 @@ func (t *taskNodeImpl) Delete() {
 	panic("couldn't find t in parent in order to delete")
 }
 
+func nodeImpl(node TaskNode) *taskNodeImpl {
+	switch n := node.(type) {
+	case *taskImpl:
+		return n.taskNodeImpl
+	case *taskListImpl:
+		return n.taskNodeImpl
+	}
+	panic("unsupported TaskNode implementation")
+}
+
+func rootNode(node TaskNode) TaskNode {
+	for node.Parent() != nil {
+		node = node.Parent()
+	}
+	return node
+}
+
 type taskImpl struct {
 	*taskNodeImpl
 	text               string
+	note               string
 	priority           Priority
 	created, completed time.Time
+	due                time.Time
+	tags               []string
 }
 
 func newTask(id int, text string, priority Priority) Task {
-	return &taskImpl{
+	task := &taskImpl{
 		taskNodeImpl: newTaskNode(id),
 		text:         text,
 		priority:     priority,
 		created:      time.Now().UTC(),
 		completed:    time.Time{},
 	}
+	task.node = task
+	return task
 }
 
 func (t *taskImpl) ID() int {
 	return t.id
 }
 
+func (t *taskImpl) SetID(id int) {
+	t.id = id
+}
+
 func (t *taskImpl) SetCreationTime(time time.Time) {
 	t.created = time
 }
@@ -281,6 +340,14 @@ func (t *taskImpl) CompletionTime() time.Time {
 	return t.completed
 }
 
+func (t *taskImpl) SetDueTime(time time.Time) {
+	t.due = time
+}
+
+func (t *taskImpl) DueTime() time.Time {
+	return t.due
+}
+
 func (t *taskImpl) Text() string {
 	return t.text
 }
@@ -289,6 +356,14 @@ func (t *taskImpl) SetText(text string) {
 	t.text = text
 }
 
+func (t *taskImpl) Note() string {
+	return t.note
+}
+
+func (t *taskImpl) SetNote(note string) {
+	t.note = note
+}
+
 func (t *taskImpl) Priority() Priority {
 	return t.priority
 }
@@ -297,20 +372,46 @@ func (t *taskImpl) SetPriority(priority Priority) {
 	t.priority = priority
 }
 
+func (t *taskImpl) Tags() []string {
+	return t.tags
+}
+
+func (t *taskImpl) SetTags(tags []string) {
+	t.tags = tags
+}
+
 func (t *taskImpl) Attributes() map[string]string {
 	return t.attributes
 }
 
 type taskListImpl struct {
 	*taskNodeImpl
-	title string
+	title  string
+	nextID int
 }
 
 func NewTaskList() TaskList {
-	return &taskListImpl{
+	tasks := &taskListImpl{
 		taskNodeImpl: newTaskNode(-1),
 		title:        "",
+		nextID:       1,
 	}
+	tasks.node = tasks
+	return tasks
+}
+
+func (t *taskListImpl) NextID() int {
+	return t.nextID
+}
+
+func (t *taskListImpl) SetNextID(id int) {
+	t.nextID = id
+}
+
+func (t *taskListImpl) allocateID() int {
+	id := t.nextID
+	t.nextID++
+	return id
 }
 
 // Convert "1.2.3" to int[]{0, 1, 2} ready for indexing into TaskNodes
@@ -332,6 +433,13 @@ func (t *taskListImpl) ID() int {
 }
 
 func (t *taskListImpl) Find(index string) Task {
+	if strings.HasPrefix(index, IDPrefix) {
+		id, err := strconv.Atoi(index[len(IDPrefix):])
+		if err != nil {
+			return nil
+		}
+		return t.FindByID(id)
+	}
 	numericIndex := indexFromString(index)
 	if numericIndex == nil {
 		return nil
@@ -345,6 +453,16 @@ func (t *taskListImpl) Find(index string) Task {
 	return node.(Task)
 }
 
+func (t *taskListImpl) FindByID(id int) Task {
+	matches := t.FindAll(func(task Task) bool {
+		return task.ID() == id
+	})
+	if len(matches) == 0 {
+		return nil
+	}
+	return matches[0]
+}
+
 // FindAll recursively returns all matching nodes.
 func (t *taskListImpl) FindAll(predicate func(task Task) bool) []Task {
 	return findAll(t, predicate)
@@ -369,6 +487,54 @@ func (t *taskListImpl) SetTitle(title string) {
 	t.title = title
 }
 
+// Position returns the zero-based position of node within its parent, or -1
+// if it has no parent.
+func Position(node TaskNode) int {
+	parent := node.Parent()
+	if parent == nil {
+		return -1
+	}
+	for i, child := range nodeImpl(parent).tasks {
+		if child == node {
+			return i
+		}
+	}
+	return -1
+}
+
+// IndexOf returns the Index of node relative to the root of its tree.
+func IndexOf(node TaskNode) Index {
+	index := Index{}
+	for ; node.Parent() != nil; node = node.Parent() {
+		index = append(Index{Position(node)}, index...)
+	}
+	return index
+}
+
+// String converts an Index back to its dotted, one-based form (eg. "1.2.3").
+func (i Index) String() string {
+	tokens := make([]string, len(i))
+	for j, value := range i {
+		tokens[j] = strconv.Itoa(value + 1)
+	}
+	return strings.Join(tokens, ".")
+}
+
+// MoveTask moves node below parent, at position, or at the end if position
+// is out of range.
+func MoveTask(node TaskNode, parent TaskNode, position int) {
+	if node.Parent() != nil {
+		node.Delete()
+	}
+	parent.Append(node)
+	children := nodeImpl(parent).tasks
+	if position < 0 || position >= len(children)-1 {
+		return
+	}
+	copy(children[position+1:], children[position:len(children)-1])
+	children[position] = node
+}
+
 func ReparentTask(node TaskNode, below TaskNode) {
 	node.Delete()
 	below.Append(node)
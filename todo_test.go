@@ -17,6 +17,8 @@
 package main
 
 import (
+	"bytes"
+	"strings"
 	"testing"
 )
 
@@ -60,3 +62,84 @@ func TestReparent(t *testing.T) {
 		t.Fail()
 	}
 }
+
+func TestFindByID(t *testing.T) {
+	tasks := NewTaskList()
+	a := tasks.Create("do A", MEDIUM)
+	b := tasks.Create("do B", MEDIUM)
+	c := b.Create("do C", MEDIUM)
+	a.Delete()
+	ReparentTask(c, tasks)
+	if tasks.Find("@3") != c || tasks.FindByID(2) != b || tasks.Find("@1") != nil {
+		t.Fail()
+	}
+	if IndexOf(c).String() != "2" || tasks.Find("2") != c {
+		t.Fail()
+	}
+}
+
+func TestIDsSurviveSerialization(t *testing.T) {
+	tasks := NewTaskList()
+	tasks.Create("do A", MEDIUM).Delete()
+	tasks.Create("do B", MEDIUM).Create("do C", MEDIUM)
+	buf := &bytes.Buffer{}
+	if err := NewJSONIO().Serialize(buf, tasks); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := NewJSONIO().Deserialize(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded.Find("@3") == nil || loaded.Find("@3").Text() != "do C" || loaded.NextID() != 4 {
+		t.Fail()
+	}
+	if loaded.Create("do D", MEDIUM).ID() != 4 {
+		t.Fail()
+	}
+}
+
+func TestDuplicateIDsReassigned(t *testing.T) {
+	data := `{"tasks": [{"id": 2, "text": "do A", "tasks": [{"id": 2, "text": "do B"}]}, {"id": 5, "text": "do C"}, {"text": "do D"}], "next_id": 6}`
+	loaded, err := NewJSONIO().Deserialize(strings.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded.Find("@2").Text() != "do A" || loaded.Find("@6").Text() != "do B" || loaded.Find("@7").Text() != "do D" {
+		t.Error("duplicate and missing IDs should be reassigned")
+	}
+	if loaded.Find("@5").Text() != "do C" || loaded.NextID() != 8 {
+		t.Error("unique IDs should be kept")
+	}
+}
+
+func TestAttributesSurviveSerialization(t *testing.T) {
+	tasks := NewTaskList()
+	tasks.Create("do A", MEDIUM).Attributes()["ticket"] = "ABC-1"
+	buf := &bytes.Buffer{}
+	if err := NewJSONIO().Serialize(buf, tasks); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := NewJSONIO().Deserialize(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded.Find("1").Attributes()["ticket"] != "ABC-1" {
+		t.Fail()
+	}
+}
+
+func TestNotesSurviveSerialization(t *testing.T) {
+	tasks := NewTaskList()
+	tasks.Create("do A", MEDIUM).SetNote("first line\nsecond line")
+	buf := &bytes.Buffer{}
+	if err := NewJSONIO().Serialize(buf, tasks); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := NewJSONIO().Deserialize(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded.Find("1").Note() != "first line\nsecond line" {
+		t.Fail()
+	}
+}
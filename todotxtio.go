@@ -0,0 +1,233 @@
+/*
+  Copyright 2011 Alec Thomas
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+*/
+
+// Reads and writes task lists in the todo.txt format
+// (https://github.com/todotxt/todo.txt), eg.
+//
+//   (B) 2026-03-01 Fix the parser +backend @alice due:2026-03-08 id:3
+//   x 2026-03-04 2026-03-02 Write a test parent:3 pri:D
+//
+// Priorities (A) to (E) map from veryhigh to verylow, and medium is written
+// without a priority. As in other todo.txt tools, the priority of completed
+// tasks is kept in a pri: attribute. Tags are +project and @context words,
+// and other key:value words following the text are attributes, except for
+// due:, which is the due date. key:value words within the text are kept as
+// text. The hierarchy is recorded by giving parents an id: attribute, which
+// their children refer to with parent:. Dates are only recorded to the day,
+// and notes and the title are not preserved.
+
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const todoTxtDate = "2006-01-02"
+
+var todoTxtPriorityPattern = regexp.MustCompile(`^\(([A-Z])\)$`)
+var todoTxtAttributePattern = regexp.MustCompile(`^([A-Za-z][\w\-.]*):(\S+)$`)
+
+var todoTxtPriorities = map[Priority]string{
+	VERYHIGH: "A",
+	HIGH:     "B",
+	LOW:      "D",
+	VERYLOW:  "E",
+}
+
+// Attributes used to encode task fields, which are not written from
+// Attributes().
+var todoTxtReservedAttributes = []string{"id", "parent", "due", "pri"}
+
+type todoTxtIO struct {
+	TaskListIO
+}
+
+func NewTodoTxtIO() TaskListIO {
+	return &todoTxtIO{}
+}
+
+func todoTxtPriority(letter string) Priority {
+	for priority, candidate := range todoTxtPriorities {
+		if candidate == letter {
+			return priority
+		}
+	}
+	if letter == "C" {
+		return MEDIUM
+	}
+	// Letters after E are all very low.
+	return VERYLOW
+}
+
+func parseTodoTxtDate(text string) (time.Time, bool) {
+	date, err := time.ParseInLocation(todoTxtDate, text, time.Local)
+	return date.UTC(), err == nil
+}
+
+// URLs are not attributes.
+func isTodoTxtAttribute(word string) bool {
+	match := todoTxtAttributePattern.FindStringSubmatch(word)
+	return match != nil && !strings.HasPrefix(match[2], "//")
+}
+
+func (t *todoTxtIO) Deserialize(reader io.Reader) (TaskList, error) {
+	tasks := NewTaskList()
+	type parsedTask struct {
+		task   Task
+		id     string
+		parent string
+	}
+	parsed := []parsedTask{}
+	byID := map[string]Task{}
+	scanner := bufio.NewScanner(reader)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		// Tasks are created at the root, then moved below their parents once
+		// all have been read.
+		task := tasks.Create("", MEDIUM)
+		entry := parsedTask{task: task}
+		if fields[0] == "x" {
+			fields = fields[1:]
+			task.SetCompleted()
+			if len(fields) > 0 {
+				if date, ok := parseTodoTxtDate(fields[0]); ok {
+					task.SetCompletionTime(date)
+					fields = fields[1:]
+				}
+			}
+		}
+		if len(fields) > 0 {
+			if match := todoTxtPriorityPattern.FindStringSubmatch(fields[0]); match != nil {
+				task.SetPriority(todoTxtPriority(match[1]))
+				fields = fields[1:]
+			}
+		}
+		if len(fields) > 0 {
+			if date, ok := parseTodoTxtDate(fields[0]); ok {
+				task.SetCreationTime(date)
+				fields = fields[1:]
+			}
+		}
+		// Attributes are only read from the words following the text.
+		text := len(fields)
+		for text > 0 && (tagPattern.MatchString(fields[text-1]) || isTodoTxtAttribute(fields[text-1])) {
+			text--
+		}
+		words := []string{}
+		tags := []string{}
+		for i, field := range fields {
+			match := todoTxtAttributePattern.FindStringSubmatch(field)
+			switch {
+			case tagPattern.MatchString(field):
+				tags = append(tags, field)
+			case i < text || !isTodoTxtAttribute(field):
+				words = append(words, field)
+			case match[1] == "id":
+				entry.id = match[2]
+			case match[1] == "parent":
+				entry.parent = match[2]
+			case match[1] == "pri":
+				task.SetPriority(todoTxtPriority(match[2]))
+			case match[1] == "due":
+				if due, ok := parseTodoTxtDate(match[2]); ok {
+					task.SetDueTime(endOfDay(due.Local()))
+				} else {
+					words = append(words, field)
+				}
+			default:
+				task.Attributes()[match[1]] = match[2]
+			}
+		}
+		task.SetText(strings.Join(words, " "))
+		task.SetTags(tags)
+		if entry.id != "" {
+			byID[entry.id] = task
+		}
+		parsed = append(parsed, entry)
+	}
+	for _, entry := range parsed {
+		if parent, ok := byID[entry.parent]; ok && !isAncestor(entry.task, parent) {
+			ReparentTask(entry.task, parent)
+		}
+	}
+	return tasks, scanner.Err()
+}
+
+// Whether node is ancestor, or ancestor itself.
+func isAncestor(ancestor, node TaskNode) bool {
+	for ; node != nil; node = node.Parent() {
+		if node == ancestor {
+			return true
+		}
+	}
+	return false
+}
+
+func (t *todoTxtIO) Serialize(writer io.Writer, tasks TaskList) error {
+	out := bufio.NewWriter(writer)
+	var write func(task Task)
+	write = func(task Task) {
+		words := []string{}
+		priority, hasPriority := todoTxtPriorities[task.Priority()]
+		done := !task.CompletionTime().IsZero()
+		if done {
+			words = append(words, "x", task.CompletionTime().Local().Format(todoTxtDate))
+		} else if hasPriority {
+			words = append(words, "("+priority+")")
+		}
+		words = append(words, task.CreationTime().Local().Format(todoTxtDate))
+		if task.Text() != "" {
+			words = append(words, task.Text())
+		}
+		words = append(words, task.Tags()...)
+		for _, key := range sortedAttributeKeys(task) {
+			value := task.Attributes()[key]
+			// Attributes that can not be represented are dropped.
+			if !containsString(todoTxtReservedAttributes, key) && todoTxtAttributePattern.MatchString(key+":"+value) {
+				words = append(words, key+":"+value)
+			}
+		}
+		if !task.DueTime().IsZero() {
+			words = append(words, "due:"+task.DueTime().Local().Format(todoTxtDate))
+		}
+		if task.Len() > 0 {
+			words = append(words, "id:"+strconv.Itoa(task.ID()))
+		}
+		if parent, ok := task.Parent().(Task); ok {
+			words = append(words, "parent:"+strconv.Itoa(parent.ID()))
+		}
+		if done && hasPriority {
+			words = append(words, "pri:"+priority)
+		}
+		fmt.Fprintln(out, strings.Join(words, " "))
+		for i := 0; i < task.Len(); i++ {
+			write(task.At(i))
+		}
+	}
+	for i := 0; i < tasks.Len(); i++ {
+		write(tasks.At(i))
+	}
+	return out.Flush()
+}
@@ -0,0 +1,80 @@
+/*
+  Copyright 2011 Alec Thomas
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+*/
+
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestTodoTxtRoundTrip(t *testing.T) {
+	text := `(A) 2026-03-01 Fix the parser +backend @alice due:2026-03-08 owner:bob id:7
+x 2026-03-04 2026-03-02 Write a test parent:7 pri:D
+2026-03-05 Tidy up
+2026-03-06 Check the ratio re:budget with finance owner:carol
+`
+	tasks, err := NewTodoTxtIO().Deserialize(strings.NewReader(text))
+	if err != nil {
+		t.Fatal(err)
+	}
+	parser := tasks.At(0)
+	if tasks.Len() != 3 || parser.Len() != 1 || parser.Priority() != VERYHIGH || len(parser.Tags()) != 2 {
+		t.Fatal("unexpected task list")
+	}
+	if parser.Attributes()["owner"] != "bob" || parser.DueTime().IsZero() || parser.Attributes()["id"] != "" {
+		t.Errorf("unexpected attributes %v", parser.Attributes())
+	}
+	if test := parser.At(0); test.Priority() != LOW || test.CompletionTime().IsZero() || test.Text() != "Write a test" {
+		t.Error("unexpected completed task")
+	}
+	if check := tasks.At(2); check.Text() != "Check the ratio re:budget with finance" || len(check.Attributes()) != 1 {
+		t.Errorf("key:value words in text should be kept, got %q %v", check.Text(), check.Attributes())
+	}
+
+	// IDs are renumbered when read, so the parent is written with its new ID.
+	out := &bytes.Buffer{}
+	if err = NewTodoTxtIO().Serialize(out, tasks); err != nil {
+		t.Fatal(err)
+	}
+	expected := `(A) 2026-03-01 Fix the parser +backend @alice owner:bob due:2026-03-08 id:1
+x 2026-03-04 2026-03-02 Write a test parent:1 pri:D
+2026-03-05 Tidy up
+2026-03-06 Check the ratio re:budget with finance owner:carol
+`
+	if out.String() != expected {
+		t.Errorf("round trip failed, expected:\n%sgot:\n%s", expected, out)
+	}
+}
+
+func TestTodoTxtParsing(t *testing.T) {
+	text := "(Z) see https://example.com ratio:3 +x id:1 parent:2\n(B) cycle id:2 parent:1\n\nno dates\n"
+	tasks, err := NewTodoTxtIO().Deserialize(strings.NewReader(text))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tasks.Len() != 2 || tasks.At(0).Len() != 1 {
+		t.Fatal("parent cycles should not detach tasks")
+	}
+	task := tasks.At(0).At(0)
+	if task.Priority() != VERYLOW || task.Text() != "see https://example.com" || task.Attributes()["ratio"] != "3" {
+		t.Errorf("unexpected task %q %v", task.Text(), task.Attributes())
+	}
+	if tasks.At(1).Text() != "no dates" {
+		t.Error("unexpected task without dates")
+	}
+}
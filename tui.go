@@ -0,0 +1,580 @@
+/*
+  Copyright 2011 Alec Thomas
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+*/
+
+// Interactive full-screen interface.
+//
+// The tui type holds all state and handles keys independently of the
+// terminal, which is only touched by doTUI.
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+	"unicode/utf8"
+	"unsafe"
+)
+
+const tuiHelp = "j/k move  h/l fold  space done  +/- priority  a/A add  e edit  x remove  J/K/</> move  / filter  . all  q quit"
+
+// Restores the terminal if the TUI is active. Called by fatalf.
+var restoreTerminal func()
+
+type tuiRow struct {
+	task  Task
+	depth int
+}
+
+// A single line text prompt shown at the bottom of the screen.
+type tuiPrompt struct {
+	label  string
+	editor *lineEditor
+	accept func(text string)
+	// Optional, called whenever the text changes.
+	change func(text string)
+	cancel func()
+}
+
+type tui struct {
+	tasks     TaskList
+	save      func(tasks TaskList)
+	collapsed map[int]bool
+	showAll   bool
+	filter    string
+	rows      []tuiRow
+	cursor    int
+	offset    int
+	message   string
+	prompt    *tuiPrompt
+	// Run by the next key if it is "y".
+	confirm func()
+	quit    bool
+}
+
+func newTUI(tasks TaskList, save func(tasks TaskList)) *tui {
+	t := &tui{
+		tasks:     tasks,
+		save:      save,
+		collapsed: map[int]bool{},
+		message:   tuiHelp,
+	}
+	t.refresh()
+	return t
+}
+
+func (t *tui) selected() Task {
+	if t.cursor < len(t.rows) {
+		return t.rows[t.cursor].task
+	}
+	return nil
+}
+
+// Predicate for the live filter. Valid filter expressions are used as is,
+// anything else matches task text and tags.
+func (t *tui) predicate() Predicate {
+	var filter Predicate
+	if t.filter != "" {
+		var err error
+		if filter, err = CompileFilter(t.filter, time.Now()); err != nil {
+			needle := strings.ToLower(t.filter)
+			filter = func(task Task) bool {
+				text := strings.Join(append([]string{task.Text()}, task.Tags()...), " ")
+				return strings.Contains(strings.ToLower(text), needle)
+			}
+		}
+	}
+	if !t.showAll {
+		filter = And(NotDone, filter)
+	}
+	return filter
+}
+
+// Rebuild the visible rows, keeping the cursor on the same task if possible.
+func (t *tui) refresh() {
+	selected := t.selected()
+	options := &ViewOptions{Order: INDEX, Filter: t.predicate()}
+	t.rows = t.rows[:0]
+	var walk func(node TaskNode, depth int)
+	walk = func(node TaskNode, depth int) {
+		view := CreateTaskView(node, options)
+		for i := 0; i < view.Len(); i++ {
+			task := view.At(i)
+			t.rows = append(t.rows, tuiRow{task, depth})
+			if t.filter != "" || !t.collapsed[task.ID()] {
+				walk(task, depth+1)
+			}
+		}
+	}
+	walk(t.tasks, 0)
+	t.selectTask(selected)
+}
+
+func (t *tui) selectTask(task Task) {
+	for i, row := range t.rows {
+		if row.task == task {
+			t.cursor = i
+			return
+		}
+	}
+	if t.cursor >= len(t.rows) {
+		t.cursor = len(t.rows) - 1
+	}
+	if t.cursor < 0 {
+		t.cursor = 0
+	}
+}
+
+func (t *tui) changed() {
+	t.save(t.tasks)
+	t.refresh()
+}
+
+func (t *tui) move(delta int) {
+	t.cursor += delta
+	if t.cursor >= len(t.rows) {
+		t.cursor = len(t.rows) - 1
+	}
+	if t.cursor < 0 {
+		t.cursor = 0
+	}
+}
+
+func (t *tui) startPrompt(label, text string, accept func(text string)) {
+	t.prompt = &tuiPrompt{label: label, editor: newLineEditor(text, nil), accept: accept}
+}
+
+// Add a new task after the selected task, or as its last child.
+func (t *tui) add(child bool) {
+	label := "Add: "
+	if child {
+		label = "Add sub-task: "
+	}
+	t.startPrompt(label, "", func(text string) {
+		text, tags := ParseTags(text)
+		if text == "" {
+			return
+		}
+		selected := t.selected()
+		var parent TaskNode = t.tasks // -golint
+		position := -1
+		if selected != nil && child {
+			parent = selected
+			delete(t.collapsed, selected.ID())
+		} else if selected != nil {
+			parent = selected.Parent()
+			position = Position(selected) + 1
+		}
+		task := parent.Create(text, PriorityFromString(config.Priority))
+		AddTags(task, tags...)
+		MoveTask(task, parent, position)
+		t.changed()
+		t.selectTask(task)
+	})
+}
+
+func (t *tui) handleKey(key string) {
+	if t.prompt != nil {
+		t.handlePromptKey(key)
+		return
+	}
+	if t.confirm != nil {
+		confirm := t.confirm
+		t.confirm = nil
+		t.message = ""
+		if key == "y" {
+			confirm()
+		}
+		return
+	}
+	t.message = ""
+	task := t.selected()
+	switch key {
+	case "q", "ctrl-c":
+		t.quit = true
+	case "?":
+		t.message = tuiHelp
+	case "j", "down":
+		t.move(1)
+	case "k", "up":
+		t.move(-1)
+	case "g", "home":
+		t.cursor = 0
+	case "G", "end":
+		t.move(len(t.rows))
+	case "pgdown", "ctrl-f":
+		t.move(10)
+	case "pgup", "ctrl-b":
+		t.move(-10)
+	case ".":
+		t.showAll = !t.showAll
+		t.refresh()
+	case "/":
+		previous := t.filter
+		t.startPrompt("Filter: ", t.filter, func(string) {})
+		t.prompt.change = func(text string) {
+			t.filter = text
+			t.refresh()
+		}
+		t.prompt.cancel = func() {
+			t.filter = previous
+			t.refresh()
+		}
+	case "a":
+		t.add(false)
+	case "A":
+		t.add(true)
+	}
+	if task == nil {
+		return
+	}
+	switch key {
+	case "h", "left":
+		if task.Len() > 0 && !t.collapsed[task.ID()] && t.filter == "" {
+			t.collapsed[task.ID()] = true
+			t.refresh()
+		} else if parent, ok := task.Parent().(Task); ok {
+			t.selectTask(parent)
+		}
+	case "l", "right":
+		if t.collapsed[task.ID()] {
+			delete(t.collapsed, task.ID())
+			t.refresh()
+		} else if t.cursor+1 < len(t.rows) && t.rows[t.cursor+1].task.Parent() == task {
+			t.move(1)
+		}
+	case "enter":
+		if t.collapsed[task.ID()] {
+			delete(t.collapsed, task.ID())
+		} else if task.Len() > 0 {
+			t.collapsed[task.ID()] = true
+		}
+		t.refresh()
+	case " ", "d":
+		if task.CompletionTime().IsZero() {
+			task.SetCompleted()
+		} else {
+			task.SetCompletionTime(time.Time{})
+		}
+		t.changed()
+	case "+", "=":
+		if task.Priority() > VERYHIGH {
+			task.SetPriority(task.Priority() - 1)
+			t.changed()
+		}
+	case "-":
+		if task.Priority() < VERYLOW {
+			task.SetPriority(task.Priority() + 1)
+			t.changed()
+		}
+	case "e":
+		text := strings.Join(append([]string{task.Text()}, task.Tags()...), " ")
+		t.startPrompt("Edit: ", text, func(text string) {
+			text, tags := ParseTags(text)
+			if text == "" {
+				return
+			}
+			task.SetText(text)
+			task.SetTags(tags)
+			t.changed()
+		})
+	case "x", "delete":
+		t.message = fmt.Sprintf("Remove '%s' and its sub-tasks? (y/n)", task.Text())
+		t.confirm = func() {
+			task.Delete()
+			t.changed()
+		}
+	case "J":
+		if position := Position(task); position+1 < task.Parent().Len() {
+			MoveTask(task, task.Parent(), position+1)
+			t.changed()
+		}
+	case "K":
+		if position := Position(task); position > 0 {
+			MoveTask(task, task.Parent(), position-1)
+			t.changed()
+		}
+	case ">":
+		if position := Position(task); position > 0 {
+			sibling := task.Parent().At(position - 1)
+			ReparentTask(task, sibling)
+			delete(t.collapsed, sibling.ID())
+			t.changed()
+		}
+	case "<":
+		if parent, ok := task.Parent().(Task); ok {
+			MoveTask(task, parent.Parent(), Position(parent)+1)
+			t.changed()
+		}
+	}
+}
+
+func (t *tui) handlePromptKey(key string) {
+	p := t.prompt
+	before := p.editor.String()
+	switch p.editor.HandleKey(key) {
+	case LINEACCEPTED:
+		t.prompt = nil
+		p.accept(strings.TrimSpace(p.editor.String()))
+	case LINECANCELLED:
+		t.prompt = nil
+		if p.cancel != nil {
+			p.cancel()
+		}
+	default:
+		if p.change != nil && p.editor.String() != before {
+			p.change(p.editor.String())
+		}
+	}
+}
+
+// Write text, truncated to at most width runes, returning the width left.
+func writeTruncated(w io.Writer, text string, width int) int {
+	if width <= 0 {
+		return 0
+	}
+	runes := []rune(text)
+	if len(runes) > width {
+		runes = runes[:width]
+	}
+	fmt.Fprint(w, string(runes))
+	return width - len(runes)
+}
+
+func (t *tui) render(w io.Writer, width, height int) {
+	// Keep the cursor on screen, leaving room for the header and footer.
+	lines := height - 2
+	if t.cursor < t.offset {
+		t.offset = t.cursor
+	}
+	if t.cursor >= t.offset+lines {
+		t.offset = t.cursor - lines + 1
+	}
+	fmt.Fprint(w, "\x1b[H"+TITLE_COLOUR)
+	title := t.tasks.Title()
+	if title == "" {
+		title = "todo2"
+	}
+	if t.filter != "" {
+		title += " [filter: " + t.filter + "]"
+	}
+	if t.showAll {
+		title += " [all]"
+	}
+	writeTruncated(w, title, width)
+	fmt.Fprint(w, RESET+"\x1b[K\r\n")
+	for i := t.offset; i < t.offset+lines; i++ {
+		if i < len(t.rows) {
+			t.renderRow(w, t.rows[i], i == t.cursor, width)
+		}
+		fmt.Fprint(w, RESET+"\x1b[K\r\n")
+	}
+	switch {
+	case t.prompt != nil:
+		left := writeTruncated(w, t.prompt.label, width)
+		writeTruncated(w, t.prompt.editor.String(), left)
+		column := len([]rune(t.prompt.label)) + t.prompt.editor.pos + 1
+		fmt.Fprintf(w, "\x1b[K\x1b[%d;%dH\x1b[?25h", height, column)
+	default:
+		fmt.Fprint(w, DIM)
+		writeTruncated(w, t.message, width)
+		fmt.Fprint(w, RESET+"\x1b[K\x1b[?25l")
+	}
+}
+
+func (t *tui) renderRow(w io.Writer, row tuiRow, selected bool, width int) {
+	task := row.task
+	fold := ' '
+	if task.Len() > 0 {
+		fold = '-'
+		if t.collapsed[task.ID()] && t.filter == "" {
+			fold = '+'
+		}
+	}
+	state := ' '
+	if !task.CompletionTime().IsZero() {
+		state = 'x'
+	}
+	highlight := ""
+	if selected {
+		highlight = REVERSE
+	}
+	fmt.Fprint(w, highlight)
+	left := writeTruncated(w, fmt.Sprintf("%s%c [%c] ", strings.Repeat("    ", row.depth), fold, state), width)
+	if state == 'x' && DONE_COLOUR != "" {
+		fmt.Fprint(w, RESET+DONE_COLOUR+highlight)
+	} else {
+		fmt.Fprint(w, colourPriorityMap[task.Priority()]+highlight)
+	}
+	left = writeTruncated(w, task.Text(), left)
+	if len(task.Tags()) > 0 {
+		fmt.Fprint(w, RESET+TAG_COLOUR+highlight)
+		left = writeTruncated(w, " "+strings.Join(task.Tags(), " "), left)
+	}
+	if !task.DueTime().IsZero() {
+		now := time.Now()
+		fmt.Fprint(w, RESET+colourDueMap[TaskDueState(task, now)]+highlight)
+		left = writeTruncated(w, " (due "+FormatDueTime(task.DueTime(), now)+")", left)
+	}
+	if selected {
+		// Extend the highlight across the full width.
+		fmt.Fprint(w, RESET+REVERSE+strings.Repeat(" ", left))
+	}
+}
+
+var tuiKeySequences = []struct {
+	sequence, key string
+}{
+	{"\x1b[A", "up"}, {"\x1b[B", "down"}, {"\x1b[C", "right"}, {"\x1b[D", "left"},
+	{"\x1bOA", "up"}, {"\x1bOB", "down"}, {"\x1bOC", "right"}, {"\x1bOD", "left"},
+	{"\x1b[H", "home"}, {"\x1b[F", "end"}, {"\x1bOH", "home"}, {"\x1bOF", "end"},
+	{"\x1b[1~", "home"}, {"\x1b[4~", "end"}, {"\x1b[3~", "delete"},
+	{"\x1b[5~", "pgup"}, {"\x1b[6~", "pgdown"},
+	{"\x1b[1;5C", "ctrl-right"}, {"\x1b[1;5D", "ctrl-left"},
+	{"\x1bb", "alt-b"}, {"\x1bf", "alt-f"}, {"\x1bd", "alt-d"}, {"\x1b\x7f", "alt-backspace"},
+}
+
+// Split raw terminal input into key names, eg. "a", "enter" or "ctrl-w".
+func parseKeys(data string) []string {
+	keys := []string{}
+next:
+	for len(data) > 0 {
+		for _, s := range tuiKeySequences {
+			if strings.HasPrefix(data, s.sequence) {
+				keys = append(keys, s.key)
+				data = data[len(s.sequence):]
+				continue next
+			}
+		}
+		c := data[0]
+		switch {
+		case strings.HasPrefix(data, "\x1b["):
+			// Skip unknown control sequences up to their final byte.
+			end := 2
+			for end < len(data) && (data[end] < 0x40 || data[end] > 0x7e) {
+				end++
+			}
+			data = data[min(end+1, len(data)):]
+			continue
+		case c == 0x1b:
+			keys = append(keys, "esc")
+		case c == '\r' || c == '\n':
+			keys = append(keys, "enter")
+		case c == '\t':
+			keys = append(keys, "tab")
+		case c == 0x7f || c == 0x08:
+			keys = append(keys, "backspace")
+		case c < 0x20:
+			keys = append(keys, "ctrl-"+string(rune('a'+c-1)))
+		default:
+			r, size := utf8.DecodeRuneInString(data)
+			keys = append(keys, string(r))
+			data = data[size:]
+			continue
+		}
+		data = data[1:]
+	}
+	return keys
+}
+
+func terminalSize(fd uintptr) (width, height int) {
+	ws := struct{ row, col, xpixel, ypixel uint16 }{}
+	syscall.Syscall(syscall.SYS_IOCTL, fd, syscall.TIOCGWINSZ, uintptr(unsafe.Pointer(&ws)))
+	return int(ws.col), int(ws.row)
+}
+
+// Put the terminal into raw mode, returning a function that restores it.
+func makeRaw(fd uintptr) (func(), error) {
+	old := syscall.Termios{}
+	if _, _, err := syscall.Syscall(syscall.SYS_IOCTL, fd, ioctlGetTermios, uintptr(unsafe.Pointer(&old))); err != 0 {
+		return nil, err
+	}
+	raw := old
+	raw.Iflag &^= syscall.BRKINT | syscall.ICRNL | syscall.INPCK | syscall.ISTRIP | syscall.IXON
+	raw.Lflag &^= syscall.ECHO | syscall.ICANON | syscall.IEXTEN | syscall.ISIG
+	raw.Cflag |= syscall.CS8
+	raw.Cc[syscall.VMIN] = 1
+	raw.Cc[syscall.VTIME] = 0
+	if _, _, err := syscall.Syscall(syscall.SYS_IOCTL, fd, ioctlSetTermios, uintptr(unsafe.Pointer(&raw))); err != 0 {
+		return nil, err
+	}
+	return func() {
+		syscall.Syscall(syscall.SYS_IOCTL, fd, ioctlSetTermios, uintptr(unsafe.Pointer(&old)))
+	}, nil
+}
+
+func doTUI(tasks TaskList) {
+	if !isTerminal(os.Stdin.Fd()) || !isTerminal(os.Stdout.Fd()) {
+		fatalf("--tui requires a terminal")
+	}
+	restore, err := makeRaw(os.Stdin.Fd())
+	if err != nil {
+		fatalf("failed to configure terminal: %s", err)
+	}
+	restoreTerminal = func() {
+		restoreTerminal = nil
+		fmt.Print("\x1b[?25h\x1b[?1049l")
+		restore()
+	}
+	defer func() {
+		if restoreTerminal != nil {
+			restoreTerminal()
+		}
+	}()
+	fmt.Print("\x1b[?1049h")
+
+	keys := make(chan string)
+	go func() {
+		buffer := make([]byte, 256)
+		for {
+			n, err := os.Stdin.Read(buffer)
+			if err != nil {
+				close(keys)
+				return
+			}
+			for _, key := range parseKeys(string(buffer[:n])) {
+				keys <- key
+			}
+		}
+	}()
+	resized := make(chan os.Signal, 1)
+	signal.Notify(resized, syscall.SIGWINCH)
+	defer signal.Stop(resized)
+
+	ui := newTUI(tasks, saveTaskList)
+	screen := &strings.Builder{}
+	for !ui.quit {
+		screen.Reset()
+		width, height := terminalSize(os.Stdout.Fd())
+		if width <= 0 || height <= 2 {
+			width, height = 80, 24
+		}
+		ui.render(screen, width, height)
+		os.Stdout.WriteString(screen.String())
+		select {
+		case key, ok := <-keys:
+			if !ok {
+				return
+			}
+			ui.handleKey(key)
+		case <-resized:
+		}
+	}
+}
@@ -0,0 +1,82 @@
+/*
+  Copyright 2011 Alec Thomas
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+*/
+
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseKeys(t *testing.T) {
+	keys := parseKeys("j\x1b[A\r\x7f\x17é\x1b[1;5C\x1b[9Z\x1b")
+	expected := []string{"j", "up", "enter", "backspace", "ctrl-w", "é", "ctrl-right", "esc"}
+	if !reflect.DeepEqual(keys, expected) {
+		t.Errorf("expected %v, got %v", expected, keys)
+	}
+}
+
+func TestTUIEditing(t *testing.T) {
+	tasks := NewTaskList()
+	a := tasks.Create("do A", MEDIUM)
+	a.Create("do B", MEDIUM)
+	c := tasks.Create("do C", MEDIUM)
+	saves := 0
+	ui := newTUI(tasks, func(TaskList) { saves++ })
+	press := func(keys ...string) {
+		for _, key := range keys {
+			ui.handleKey(key)
+		}
+	}
+
+	if len(ui.rows) != 3 || ui.rows[1].depth != 1 {
+		t.Fatalf("unexpected rows %v", ui.rows)
+	}
+	press("h")
+	if len(ui.rows) != 2 {
+		t.Error("collapse failed")
+	}
+	press("l", "G", "K", "+", "d")
+	if tasks.At(0) != c || c.Priority() != HIGH || c.CompletionTime().IsZero() {
+		t.Error("move, priority or done failed")
+	}
+	if len(ui.rows) != 2 || ui.selected() != a {
+		t.Error("done task should be hidden and the cursor moved")
+	}
+	press("a", "d", "o", " ", "D", " ", "+", "x", "enter")
+	if tasks.Len() != 3 || tasks.At(2).Text() != "do D" || tasks.At(2).Tags()[0] != "+x" {
+		t.Error("add failed")
+	}
+	press(">", "e", "ctrl-w", "ctrl-w", "E", "enter")
+	if a.Len() != 2 || a.At(1).Text() != "do E" {
+		t.Error("indent or edit failed")
+	}
+	press("x", "n", "x", "y")
+	if a.Len() != 1 || saves != 7 {
+		t.Errorf("remove failed after %d saves", saves)
+	}
+	press("/", "B")
+	if len(ui.rows) != 2 || ui.selected().Text() != "do B" {
+		t.Error("live filter failed")
+	}
+	press("esc")
+	out := &strings.Builder{}
+	ui.render(out, 40, 10)
+	if !strings.Contains(out.String(), "do A") {
+		t.Error("render failed")
+	}
+}
@@ -24,12 +24,33 @@ type ViewOptions struct {
 	Order     Order
 	Reversed  bool
 	Summarise bool
-	ShowAll   bool
+	// Template used to format each task, or empty for the default format.
+	Format string
+	// Only show tasks matching Filter, plus their ancestors. Shows all tasks
+	// if nil.
+	Filter Predicate
+}
+
+// Visible returns true if task, or any of its descendants, match the filter
+// in options.
+func (o *ViewOptions) Visible(task Task) bool {
+	if o.Filter == nil || o.Filter(task) {
+		return true
+	}
+	for i := 0; i < task.Len(); i++ {
+		if o.Visible(task.At(i)) {
+			return true
+		}
+	}
+	return false
 }
 
 type View interface {
 	ShowTree(tasks TaskList, options *ViewOptions)
 	ShowTaskInfo(task Task)
+	ShowAttributes(task Task)
+	// Show the source locations of tasks.
+	ShowLocations(tasks []Task)
 }
 
 // TaskView is a filtered, ordered view of a Tasks children.
@@ -40,11 +61,13 @@ type TaskView struct {
 
 func CreateTaskView(node TaskNode, options *ViewOptions) *TaskView {
 	view := &TaskView{
-		tasks:   make([]Task, node.Len()),
+		tasks:   make([]Task, 0, node.Len()),
 		options: options,
 	}
 	for i := 0; i < node.Len(); i++ {
-		view.tasks[i] = node.At(i)
+		if task := node.At(i); options.Visible(task) {
+			view.tasks = append(view.tasks, task)
+		}
 	}
 	sort.Sort(view)
 	return view
@@ -64,7 +87,7 @@ func (t *TaskView) Less(i, j int) bool {
 	less := false
 	switch t.options.Order {
 	case INDEX:
-		less = left.ID() < right.ID()
+		less = Position(left) < Position(right)
 	case CREATED:
 		less = left.CreationTime().Unix() < right.CreationTime().Unix()
 	case COMPLETED:
@@ -88,6 +111,15 @@ func (t *TaskView) Less(i, j int) bool {
 			rightDuration = 0
 		}
 		less = leftDuration < rightDuration
+	case DUE:
+		// Tasks without a due time sort last.
+		leftDue := left.DueTime()
+		rightDue := right.DueTime()
+		if leftDue.IsZero() || rightDue.IsZero() {
+			less = !leftDue.IsZero() && rightDue.IsZero()
+		} else {
+			less = leftDue.Before(rightDue)
+		}
 	case DONE:
 		less = !left.CompletionTime().IsZero() && !right.CompletionTime().IsZero()
 	default: